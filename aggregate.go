@@ -0,0 +1,209 @@
+package go_mysql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var groupAliasRegexp = regexp.MustCompile(`(?i)^(.+)\s+as\s+([A-Za-z0-9_]+)$`)
+
+// Aggregate 聚合表达式，使用 CountOf、SumOf 等生成
+type Aggregate struct {
+	fn       string
+	col      string
+	distinct bool
+	as       string
+}
+
+// CountOf count(`col`) as `as`，col 为 * 时是 count(*)
+func CountOf(col string, as string) Aggregate {
+	return Aggregate{fn: "count", col: col, as: as}
+}
+
+// CountDistinctOf count(distinct `col`) as `as`
+func CountDistinctOf(col string, as string) Aggregate {
+	return Aggregate{fn: "count", col: col, distinct: true, as: as}
+}
+
+func SumOf(col string, as string) Aggregate {
+	return Aggregate{fn: "sum", col: col, as: as}
+}
+
+func AvgOf(col string, as string) Aggregate {
+	return Aggregate{fn: "avg", col: col, as: as}
+}
+
+func MinOf(col string, as string) Aggregate {
+	return Aggregate{fn: "min", col: col, as: as}
+}
+
+func MaxOf(col string, as string) Aggregate {
+	return Aggregate{fn: "max", col: col, as: as}
+}
+
+func (a Aggregate) build() (string, error) {
+	if a.fn == "" {
+		return ``, errors.New(`Aggregate must be created by CountOf, SumOf, etc.`)
+	}
+	if a.as == "" {
+		return ``, errors.New(`Aggregate alias cannot be empty.`)
+	}
+	if a.col == "" {
+		return ``, errors.Errorf(`Aggregate <%s> col cannot be empty.`, a.as)
+	}
+	col := a.col
+	if col == "*" {
+		if a.fn != "count" || a.distinct {
+			return ``, errors.Errorf(`Aggregate <%s> cannot use *.`, a.as)
+		}
+	} else {
+		col = "`" + col + "`"
+	}
+	if a.distinct {
+		col = "distinct " + col
+	}
+	return fmt.Sprintf("%s(%s) as `%s`", a.fn, col, a.as), nil
+}
+
+// GroupParams 分组聚合查询
+type GroupParams struct {
+	TableName string
+	// 分组列，也可以是带别名的表达式，比如 "date(`created_at`) as day"，结果里的列名是别名
+	GroupBy    []string
+	Aggregates []Aggregate
+	Where      interface{}
+	// 同 Where，map 的键可以是聚合的别名或者分组列，比如 map[string]interface{}{"total": Gt(100)}
+	Having interface{}
+	Orders []OrderBy
+	Limit  uint64
+}
+
+// groupColumn 返回 select 以及 group by 里的表达式，还有结果里的列名
+func groupColumn(groupBy string) (selectStr string, groupStr string, name string) {
+	groupBy = strings.TrimSpace(groupBy)
+	if identifierRegexp.MatchString(groupBy) {
+		return "`" + groupBy + "`", "`" + groupBy + "`", groupBy
+	}
+	if matches := groupAliasRegexp.FindStringSubmatch(groupBy); matches != nil {
+		return fmt.Sprintf("%s as `%s`", strings.TrimSpace(matches[1]), matches[2]), "`" + matches[2] + "`", matches[2]
+	}
+	return groupBy, groupBy, groupBy
+}
+
+func (mysql *builderClass) buildGroupSql(groupParams *GroupParams, values ...interface{}) (
+	sql string,
+	paramArgs []interface{},
+	err error,
+) {
+	if len(groupParams.GroupBy) == 0 {
+		return ``, nil, errors.New(`Group by cannot be empty.`)
+	}
+	if len(groupParams.Aggregates) == 0 {
+		return ``, nil, errors.New(`Aggregates cannot be empty.`)
+	}
+	selects := make([]string, 0, len(groupParams.GroupBy)+len(groupParams.Aggregates))
+	groups := make([]string, 0, len(groupParams.GroupBy))
+	for _, groupBy := range groupParams.GroupBy {
+		selectStr, groupStr, _ := groupColumn(groupBy)
+		selects = append(selects, selectStr)
+		groups = append(groups, groupStr)
+	}
+	for _, aggregate := range groupParams.Aggregates {
+		aggregateStr, err := aggregate.build()
+		if err != nil {
+			return ``, nil, err
+		}
+		selects = append(selects, aggregateStr)
+	}
+
+	paramArgs, whereStr, err := mysql.buildWhere(groupParams.Where, values)
+	if err != nil {
+		return ``, nil, err
+	}
+	str := fmt.Sprintf(
+		"select %s from `%s` %s group by %s",
+		strings.Join(selects, ","),
+		groupParams.TableName,
+		whereStr,
+		strings.Join(groups, ","),
+	)
+	havingArgs, havingStr, err := mysql.buildWhere(groupParams.Having, nil)
+	if err != nil {
+		return ``, nil, err
+	}
+	if havingStr != "" {
+		str += " having " + strings.TrimPrefix(havingStr, "where ")
+		paramArgs = append(paramArgs, havingArgs...)
+	}
+	if len(groupParams.Orders) > 0 {
+		orderByStr, orderByArgs, err := mysql.buildOrderBy(groupParams.Orders)
+		if err != nil {
+			return ``, nil, err
+		}
+		str += " " + orderByStr
+		paramArgs = append(paramArgs, orderByArgs...)
+	}
+	if groupParams.Limit != 0 {
+		str += fmt.Sprintf(" limit %d", groupParams.Limit)
+	}
+	return str, paramArgs, nil
+}
+
+func (mc *MysqlType) SelectGroup(dest interface{}, groupParams *GroupParams, values ...interface{}) error {
+	return mc.SelectGroupContext(context.Background(), dest, groupParams, values...)
+}
+
+// SelectGroupContext dest 是 struct slice 的指针，或者 map 的指针。
+// map 的时候只能有一个分组列和一个聚合，键是分组列的值，值是聚合的值
+func (mc *MysqlType) SelectGroupContext(
+	ctx context.Context,
+	dest interface{},
+	groupParams *GroupParams,
+	values ...interface{},
+) error {
+	sql, paramArgs, err := builder.buildGroupSql(groupParams, values...)
+	if err != nil {
+		return err
+	}
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Map {
+		return mc.rawSelect(ctx, dest, sql, paramArgs...)
+	}
+
+	if len(groupParams.GroupBy) != 1 || len(groupParams.Aggregates) != 1 {
+		return errors.New(`Map dest needs exactly one group by and one aggregate.`)
+	}
+	_, _, keyName := groupColumn(groupParams.GroupBy[0])
+	mapType := destValue.Elem().Type()
+	// 用临时的 struct 接收，再放进 map
+	rowType := reflect.StructOf([]reflect.StructField{
+		{
+			Name: "Key",
+			Type: mapType.Key(),
+			Tag:  reflect.StructTag(fmt.Sprintf(`%s:"%s"`, mc.tagName, keyName)),
+		},
+		{
+			Name: "Value",
+			Type: mapType.Elem(),
+			Tag:  reflect.StructTag(fmt.Sprintf(`%s:"%s"`, mc.tagName, groupParams.Aggregates[0].as)),
+		},
+	})
+	rows := reflect.New(reflect.SliceOf(rowType))
+	err = mc.rawSelect(ctx, rows.Interface(), sql, paramArgs...)
+	if err != nil {
+		return err
+	}
+	if destValue.Elem().IsNil() {
+		destValue.Elem().Set(reflect.MakeMapWithSize(mapType, rows.Elem().Len()))
+	}
+	for i := 0; i < rows.Elem().Len(); i++ {
+		row := rows.Elem().Index(i)
+		destValue.Elem().SetMapIndex(row.Field(0), row.Field(1))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package go_mysql
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestBuilderClass_buildGroupSql(t *testing.T) {
+	sql, args, err := builder.buildGroupSql(&GroupParams{
+		TableName: "orders",
+		GroupBy:   []string{"status", "date(`created_at`) as day"},
+		Aggregates: []Aggregate{
+			CountOf("*", "count"),
+			CountDistinctOf("user_id", "users"),
+			SumOf("amount", "total"),
+			AvgOf("amount", "average"),
+			MinOf("amount", "smallest"),
+			MaxOf("amount", "largest"),
+		},
+		Where: map[string]interface{}{"shop_id": 3},
+		Having: Or(
+			map[string]interface{}{"total": Gt(100)},
+			map[string]interface{}{"count": Gte(10)},
+		),
+		Orders: []OrderBy{{Col: "day", Order: "desc"}},
+		Limit:  30,
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(
+		t,
+		"select `status`,date(`created_at`) as `day`,count(*) as `count`,count(distinct `user_id`) as `users`,sum(`amount`) as `total`,avg(`amount`) as `average`,min(`amount`) as `smallest`,max(`amount`) as `largest` from `orders` where `shop_id` = ? group by `status`,`day` having `total` > ? or `count` >= ? order by `day` desc limit 30",
+		sql,
+	)
+	go_test_.Equal(t, []interface{}{"3", "100", "10"}, args)
+
+	_, _, err = builder.buildGroupSql(&GroupParams{TableName: "orders", Aggregates: []Aggregate{CountOf("*", "count")}})
+	go_test_.Equal(t, "Group by cannot be empty.", err.Error())
+	_, _, err = builder.buildGroupSql(&GroupParams{TableName: "orders", GroupBy: []string{"status"}, Aggregates: []Aggregate{SumOf("*", "total")}})
+	go_test_.Equal(t, "Aggregate <total> cannot use *.", err.Error())
+	_, _, err = builder.buildGroupSql(&GroupParams{TableName: "orders", GroupBy: []string{"status"}, Aggregates: []Aggregate{{}}})
+	go_test_.Equal(t, "Aggregate must be created by CountOf, SumOf, etc.", err.Error())
+}
+
+func TestMysqlType_SelectGroup(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+	recorder.rows = func(query string) ([]string, [][]driver.Value) {
+		return []string{"status", "count"}, [][]driver.Value{{"paid", int64(3)}, {"refunded", int64(1)}}
+	}
+	params := &GroupParams{
+		TableName:  "orders",
+		GroupBy:    []string{"status"},
+		Aggregates: []Aggregate{CountOf("*", "count")},
+	}
+
+	var rows []struct {
+		Status string `json:"status"`
+		Count  uint64 `json:"count"`
+	}
+	err := mysql.SelectGroup(&rows, params)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, 2, len(rows))
+	go_test_.Equal(t, "paid", rows[0].Status)
+	go_test_.Equal(t, uint64(3), rows[0].Count)
+	go_test_.Equal(t, "select `status`,count(*) as `count` from `orders`  group by `status`", recorder.Stmts()[0])
+
+	var counts map[string]uint64
+	err = mysql.SelectGroup(&counts, params)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, map[string]uint64{"paid": 3, "refunded": 1}, counts)
+
+	params.Aggregates = append(params.Aggregates, SumOf("amount", "total"))
+	err = mysql.SelectGroup(&counts, params)
+	go_test_.Equal(t, "Map dest needs exactly one group by and one aggregate.", err.Error())
+}
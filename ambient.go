@@ -0,0 +1,27 @@
+package go_mysql
+
+import "context"
+
+type txContextKey struct{}
+
+// ContextWithTx 把事务放进 ctx，非事务实例的 ...Context 方法（包括 BeginContext）拿到这个 ctx 时会在该事务中执行
+func ContextWithTx(ctx context.Context, tx *MysqlType) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+func TxFromContext(ctx context.Context) (*MysqlType, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*MysqlType)
+	return tx, ok && tx != nil && tx.tx != nil
+}
+
+// ambientTx 非事务实例遇到 ctx 中属于同一个连接池的事务时，返回该事务，否则返回自己
+func (mc *MysqlType) ambientTx(ctx context.Context) *MysqlType {
+	if mc.tx != nil {
+		return mc
+	}
+	tx, ok := TxFromContext(ctx)
+	if !ok || tx.txTracker != mc.txTracker {
+		return mc
+	}
+	return tx
+}
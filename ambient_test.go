@@ -0,0 +1,67 @@
+package go_mysql
+
+import (
+	"context"
+	"testing"
+
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestMysqlType_ContextWithTx(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+	other, otherRecorder := newFakeMysql()
+
+	err := mysql.WithTransaction(context.Background(), func(tx *MysqlType) error {
+		ctx := ContextWithTx(context.Background(), tx)
+		_, err := mysql.UpdateContext(ctx, &t_mysql.UpdateParams{
+			TableName: "table",
+			Update:    "`a` = 1",
+		})
+		if err != nil {
+			return err
+		}
+		// 其他连接池不受影响
+		_, err = other.RawExecContext(ctx, "update `other` set `a` = 1")
+		if err != nil {
+			return err
+		}
+		return mysql.WithTransaction(ctx, func(nested *MysqlType) error {
+			go_test_.Equal(t, tx, nested.parent)
+			return nil
+		})
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, []string{
+		"begin",
+		"update `table` set `a` = 1 ",
+		"savepoint sp_1",
+		"release savepoint sp_1",
+		"commit",
+	}, recorder.Stmts())
+	go_test_.Equal(t, []string{"update `other` set `a` = 1"}, otherRecorder.Stmts())
+
+	_, ok := TxFromContext(context.Background())
+	go_test_.Equal(t, false, ok)
+}
+
+func TestMysqlType_ContextWithTx_Nested(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+
+	err := mysql.WithTransaction(context.Background(), func(tx *MysqlType) error {
+		return mysql.WithTransaction(ContextWithTx(context.Background(), tx), func(nested *MysqlType) error {
+			ctx := ContextWithTx(context.Background(), nested)
+			go_test_.Equal(t, nested, mysql.ambientTx(ctx))
+			_, err := mysql.RawExecContext(ctx, "update `table` set `a` = 1")
+			return err
+		})
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, []string{
+		"begin",
+		"savepoint sp_1",
+		"update `table` set `a` = 1",
+		"release savepoint sp_1",
+		"commit",
+	}, recorder.Stmts())
+}
@@ -0,0 +1,171 @@
+package go_mysql
+
+import (
+	"context"
+	"net"
+	"time"
+
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	"github.com/pefish/go-mysql/sqlx"
+	"github.com/pkg/errors"
+)
+
+// DialContextFunc 建立到数据库的网络连接
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Configuration 在 t_mysql.Configuration 的基础上增加的连接配置
+type Configuration struct {
+	t_mysql.Configuration // 主库
+
+	// 主库的候选 host（host 或者 host:port），按优先级排列，设置了则忽略 Host。
+	// 连接时只使用可写（@@read_only = 0）的 host，当前 host 连不上或者变成只读时切换到下一个可写的 host
+	PrimaryHosts          []string
+	FailoverCheckInterval time.Duration // 检查当前 host 是否可写的间隔，默认 DEFAULT_FAILOVER_CHECK_INTERVAL
+	// 主库的 unix socket 路径，设置了则忽略 Host、Port 和 PrimaryHosts
+	Socket string
+	// 自定义建立连接的方式，network 是 tcp 或者 unix，可以直接使用 (&net.Dialer{}).DialContext。主从库共用
+	DialContext DialContextFunc
+
+	// Connect 时连接失败按指数退避重试的最长时间，0 表示不重试。
+	// 懒连接模式下表示第一次使用时等待数据库可用的最长时间，0 表示只受调用方 ctx 限制
+	ConnectRetryTimeout time.Duration
+	// 懒连接模式，Connect 时只创建连接池不连接数据库
+	LazyConnect bool
+	// 连接空闲超过这个时间就关闭，0 表示不限制。主从库共用
+	ConnMaxIdleTime time.Duration
+
+	TLS *TLSConfiguration // 主从库共用
+	// 设置了则每次建立物理连接时获取用户名和密码，忽略配置里的密码。主从库共用
+	Credentials CredentialsProvider
+	// 每个新的物理连接交给连接池之前按顺序执行，比如 set time_zone = '+00:00'。任意一条失败则丢弃该连接。主从库共用
+	InitStatements []string
+
+	Replicas []ReplicaConfiguration
+	Balancer Balancer // 从库的负载均衡策略，默认 RoundRobinBalancer
+	// 设置了则在连接成功后启动从库延迟检查
+	ReplicaLag *ReplicaLagOptions
+	// read-your-writes 读请求等待从库追上会话 gtid 的最长时间，0 表示不等待，没追上直接读主库
+	GtidWaitTimeout time.Duration
+}
+
+type ReplicaConfiguration struct {
+	t_mysql.Configuration // Host 和 Socket 必须设置一个，其他未设置的字段使用主库的配置
+
+	Socket string // 从库的 unix socket 路径，设置了则忽略 Host 和 Port
+	Weight int    // WeightedBalancer 使用，默认 1
+}
+
+// inherit 未设置的字段使用主库的配置
+func (rc *ReplicaConfiguration) inherit(primary t_mysql.Configuration) t_mysql.Configuration {
+	result := rc.Configuration
+	if result.Port == 0 {
+		result.Port = primary.Port
+	}
+	if result.Username == "" {
+		result.Username = primary.Username
+		if result.Password == "" {
+			result.Password = primary.Password
+		}
+	}
+	if result.Database == "" {
+		result.Database = primary.Database
+	}
+	if result.MaxOpenConns == 0 {
+		result.MaxOpenConns = primary.MaxOpenConns
+	}
+	if result.MaxIdleConns == 0 {
+		result.MaxIdleConns = primary.MaxIdleConns
+	}
+	if result.ConnMaxLifetime == 0 {
+		result.ConnMaxLifetime = primary.ConnMaxLifetime
+	}
+	if result.ConnParams == nil {
+		result.ConnParams = primary.ConnParams
+	}
+	return result
+}
+
+// Connect 连接主库以及所有从库，任意一个连接失败都会返回错误
+func (mc *MysqlType) Connect(ctx context.Context, configuration *Configuration) error {
+	// 在打开连接池之前检查，避免出错时留下打开的连接池
+	if configuration.ReplicaLag != nil {
+		err := configuration.ReplicaLag.validate()
+		if err != nil {
+			return err
+		}
+	}
+	db, primaryConnector, err := mc.openDb(
+		ctx,
+		configuration.Configuration,
+		configuration.PrimaryHosts,
+		configuration.Socket,
+		configuration,
+	)
+	if err != nil {
+		return err
+	}
+
+	replicas := make([]*Replica, 0, len(configuration.Replicas))
+	closeAll := func() {
+		_ = db.Close()
+		primaryConnector.close()
+		for _, replica := range replicas {
+			_ = replica.db.Close()
+			replica.connector.close()
+		}
+	}
+	for _, replicaConfiguration := range configuration.Replicas {
+		if replicaConfiguration.Host == "" && replicaConfiguration.Socket == "" {
+			closeAll()
+			return errors.New(`Replica host cannot be empty.`)
+		}
+		replicaConfig := replicaConfiguration.inherit(configuration.Configuration)
+		var replicaDb *sqlx.DB
+		var replicaConnector *connector
+		replicaDb, replicaConnector, err = mc.openDb(ctx, replicaConfig, nil, replicaConfiguration.Socket, configuration)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		replicaAddress := replicaConfig.Host
+		if replicaConfiguration.Socket != "" {
+			replicaAddress = replicaConfiguration.Socket
+		}
+		weight := replicaConfiguration.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		replicas = append(replicas, &Replica{
+			db:           replicaDb,
+			connector:    replicaConnector,
+			address:      replicaAddress,
+			weight:       weight,
+			poolSettings: poolSettingsFrom(replicaConfig, configuration.ConnMaxIdleTime),
+		})
+	}
+
+	balancer := configuration.Balancer
+	if balancer == nil {
+		balancer = &RoundRobinBalancer{}
+	}
+	mc.db = db
+	mc.connector = primaryConnector
+	mc.replicas = replicas
+	mc.balancer = balancer
+	mc.poolSettings = poolSettingsFrom(configuration.Configuration, configuration.ConnMaxIdleTime)
+	mc.gtidWaitTimeout = configuration.GtidWaitTimeout
+	if configuration.ReplicaLag != nil && len(replicas) > 0 {
+		err = mc.StartReplicaLagMonitor(*configuration.ReplicaLag)
+		if err != nil {
+			return err
+		}
+	}
+	if primaryConnector.multiHost() {
+		failoverCheckInterval := DEFAULT_FAILOVER_CHECK_INTERVAL
+		if configuration.FailoverCheckInterval != 0 {
+			failoverCheckInterval = configuration.FailoverCheckInterval
+		}
+		primaryConnector.startMonitor(failoverCheckInterval)
+	}
+	return nil
+}
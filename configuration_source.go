@@ -0,0 +1,264 @@
+package go_mysql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// 环境变量以及配置文件里的字段名。环境变量是前缀加大写的字段名，比如 APP_MYSQL_HOST
+var configurationFields = []string{
+	`host`,
+	`port`,
+	`username`,
+	`password`,
+	`database`,
+	`max_open_conns`,
+	`max_idle_conns`,
+	`conn_max_lifetime`, // time.ParseDuration 的格式，比如 30m
+	`conn_params`,       // 环境变量里是 url query 的格式，比如 charset=utf8mb4&timeout=5s
+}
+
+// ConfigurationError 指明哪个字段有问题
+type ConfigurationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ConfigurationError) Error() string {
+	return fmt.Sprintf(`Configuration field <%s> is invalid: %v`, e.Field, e.Err)
+}
+
+func (e *ConfigurationError) Unwrap() error {
+	return e.Err
+}
+
+func fieldError(field string, err error) error {
+	return errors.WithStack(&ConfigurationError{Field: field, Err: err})
+}
+
+// ConfigurationFromDSN 从 go-sql-driver 格式的 DSN 生成配置，只支持 tcp，DSN 里的参数保持转义后的值放进 ConnParams
+func ConfigurationFromDSN(dsn string) (t_mysql.Configuration, error) {
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return t_mysql.Configuration{}, fieldError(`dsn`, err)
+	}
+	if config.Net != `tcp` {
+		return t_mysql.Configuration{}, fieldError(`net`, errors.Errorf(`Network %s is not supported.`, config.Net))
+	}
+	host, portStr, err := net.SplitHostPort(config.Addr)
+	if err != nil {
+		return t_mysql.Configuration{}, fieldError(`host`, err)
+	}
+	port, err := parsePort(portStr)
+	if err != nil {
+		return t_mysql.Configuration{}, err
+	}
+	result := t_mysql.Configuration{
+		Host:     host,
+		Port:     port,
+		Username: config.User,
+		Password: config.Passwd,
+		Database: config.DBName,
+	}
+	// ParseDSN 会把已知的参数解析掉，所以直接从原始字符串取。和 ParseDSN 一样，参数在最后一个 / 之后，密码里可以有 ?
+	dbAndParams := dsn[strings.LastIndex(dsn, `/`)+1:]
+	if index := strings.Index(dbAndParams, `?`); index >= 0 {
+		result.ConnParams, err = parseConnParams(dbAndParams[index+1:])
+		if err != nil {
+			return t_mysql.Configuration{}, err
+		}
+	}
+	err = validateConfiguration(result)
+	if err != nil {
+		return t_mysql.Configuration{}, err
+	}
+	return result, nil
+}
+
+// ConfigurationFromEnv 从带前缀的环境变量生成配置，比如前缀 APP_MYSQL 读取 APP_MYSQL_HOST、APP_MYSQL_PORT 等
+func ConfigurationFromEnv(prefix string) (t_mysql.Configuration, error) {
+	prefix = strings.TrimSuffix(prefix, `_`)
+	values := make(map[string]interface{})
+	for _, field := range configurationFields {
+		value, ok := os.LookupEnv(prefix + `_` + strings.ToUpper(field))
+		if ok {
+			values[field] = value
+		}
+	}
+	return configurationFromValues(values)
+}
+
+// ConfigurationFromFile 从 json 或者 yaml 文件生成配置，根据扩展名判断格式，字段名见 configurationFields
+func ConfigurationFromFile(path string) (t_mysql.Configuration, error) {
+	values, err := readValuesFile(path)
+	if err != nil {
+		return t_mysql.Configuration{}, err
+	}
+	return configurationFromValues(values)
+}
+
+func readValuesFile(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case `.json`:
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		err = decoder.Decode(&values)
+	case `.yaml`, `.yml`:
+		err = yaml.Unmarshal(content, &values)
+	default:
+		return nil, errors.Errorf(`Configuration file <%s> is not json or yaml.`, path)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, `Parse configuration file <%s> failed.`, path)
+	}
+	return values, nil
+}
+
+func configurationFromValues(values map[string]interface{}) (t_mysql.Configuration, error) {
+	for field := range values {
+		if !isConfigurationField(field) {
+			return t_mysql.Configuration{}, fieldError(field, errors.New(`Unknown field.`))
+		}
+	}
+
+	var result t_mysql.Configuration
+	var err error
+	result.Host = stringValue(values[`host`])
+	result.Username = stringValue(values[`username`])
+	result.Password = stringValue(values[`password`])
+	result.Database = stringValue(values[`database`])
+	if value, ok := values[`port`]; ok {
+		result.Port, err = parsePort(stringValue(value))
+		if err != nil {
+			return t_mysql.Configuration{}, err
+		}
+	}
+	if value, ok := values[`max_open_conns`]; ok {
+		result.MaxOpenConns, err = parseNonNegativeInt(`max_open_conns`, stringValue(value))
+		if err != nil {
+			return t_mysql.Configuration{}, err
+		}
+	}
+	if value, ok := values[`max_idle_conns`]; ok {
+		result.MaxIdleConns, err = parseNonNegativeInt(`max_idle_conns`, stringValue(value))
+		if err != nil {
+			return t_mysql.Configuration{}, err
+		}
+	}
+	if value, ok := values[`conn_max_lifetime`]; ok {
+		result.ConnMaxLifetime, err = time.ParseDuration(stringValue(value))
+		if err != nil {
+			return t_mysql.Configuration{}, fieldError(`conn_max_lifetime`, err)
+		}
+		if result.ConnMaxLifetime < 0 {
+			return t_mysql.Configuration{}, fieldError(`conn_max_lifetime`, errors.New(`Must not be negative.`))
+		}
+	}
+	if value, ok := values[`conn_params`]; ok {
+		switch v := value.(type) {
+		case string:
+			result.ConnParams, err = parseConnParams(v)
+			if err != nil {
+				return t_mysql.Configuration{}, err
+			}
+		case map[string]interface{}:
+			result.ConnParams = make(map[string]string, len(v))
+			for k, param := range v {
+				result.ConnParams[k] = stringValue(param)
+			}
+		default:
+			return t_mysql.Configuration{}, fieldError(`conn_params`, errors.New(`Must be a map or a query string.`))
+		}
+	}
+
+	err = validateConfiguration(result)
+	if err != nil {
+		return t_mysql.Configuration{}, err
+	}
+	return result, nil
+}
+
+// validateConfiguration 检查必填字段
+func validateConfiguration(c t_mysql.Configuration) error {
+	if c.Host == "" {
+		return fieldError(`host`, errors.New(`Must not be empty.`))
+	}
+	if c.Username == "" {
+		return fieldError(`username`, errors.New(`Must not be empty.`))
+	}
+	return nil
+}
+
+func isConfigurationField(field string) bool {
+	for _, f := range configurationFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func stringValue(value interface{}) string {
+	if value == nil {
+		return ``
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fieldError(`port`, err)
+	}
+	if port <= 0 || port > 65535 {
+		return 0, fieldError(`port`, errors.Errorf(`Port %d is out of range.`, port))
+	}
+	return port, nil
+}
+
+func parseNonNegativeInt(field string, value string) (int, error) {
+	result, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fieldError(field, err)
+	}
+	if result < 0 {
+		return 0, fieldError(field, errors.New(`Must not be negative.`))
+	}
+	return result, nil
+}
+
+// parseConnParams 保留转义后的原始值，openDb 会把 ConnParams 原样拼进 DSN
+func parseConnParams(query string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, param := range strings.Split(query, `&`) {
+		if param == `` {
+			continue
+		}
+		k, v, _ := strings.Cut(param, `=`)
+		if k == `` {
+			return nil, fieldError(`conn_params`, errors.Errorf(`Invalid param <%s>.`, param))
+		}
+		result[k] = v
+	}
+	return result, nil
+}
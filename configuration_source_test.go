@@ -0,0 +1,127 @@
+package go_mysql
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	go_test_ "github.com/pefish/go-test"
+)
+
+func configurationErrorField(err error) string {
+	var configurationError *ConfigurationError
+	if !errors.As(err, &configurationError) {
+		return ""
+	}
+	return configurationError.Field
+}
+
+func TestConfigurationFromDSN(t *testing.T) {
+	c, err := ConfigurationFromDSN("root:pass@tcp(db:3307)/test?charset=utf8mb4&timeout=5s")
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, t_mysql.Configuration{
+		Host:     "db",
+		Port:     3307,
+		Username: "root",
+		Password: "pass",
+		Database: "test",
+		ConnParams: map[string]string{
+			"charset": "utf8mb4",
+			"timeout": "5s",
+		},
+	}, c)
+
+	_, err = ConfigurationFromDSN("root:pass@unix(/tmp/mysql.sock)/test")
+	go_test_.Equal(t, "net", configurationErrorField(err))
+	_, err = ConfigurationFromDSN("root:pass@tcp(db:70000)/test")
+	go_test_.Equal(t, "port", configurationErrorField(err))
+	_, err = ConfigurationFromDSN(":pass@tcp(db:3306)/test")
+	go_test_.Equal(t, "username", configurationErrorField(err))
+}
+
+func TestConfigurationFromEnv(t *testing.T) {
+	t.Setenv("APP_MYSQL_HOST", "db")
+	t.Setenv("APP_MYSQL_PORT", "3307")
+	t.Setenv("APP_MYSQL_USERNAME", "root")
+	t.Setenv("APP_MYSQL_PASSWORD", "pass")
+	t.Setenv("APP_MYSQL_MAX_OPEN_CONNS", "50")
+	t.Setenv("APP_MYSQL_CONN_MAX_LIFETIME", "30m")
+	t.Setenv("APP_MYSQL_CONN_PARAMS", "charset=utf8mb4")
+	c, err := ConfigurationFromEnv("APP_MYSQL")
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "db", c.Host)
+	go_test_.Equal(t, 3307, c.Port)
+	go_test_.Equal(t, 50, c.MaxOpenConns)
+	go_test_.Equal(t, 30*time.Minute, c.ConnMaxLifetime)
+	go_test_.Equal(t, "utf8mb4", c.ConnParams["charset"])
+
+	// 写错的端口不再被当成 0
+	t.Setenv("APP_MYSQL_PORT", "33o6")
+	_, err = ConfigurationFromEnv("APP_MYSQL_")
+	go_test_.Equal(t, "port", configurationErrorField(err))
+	go_test_.Equal(t, `Configuration field <port> is invalid: strconv.Atoi: parsing "33o6": invalid syntax`, err.Error())
+
+	t.Setenv("APP_MYSQL_PORT", "3306")
+	t.Setenv("APP_MYSQL_MAX_IDLE_CONNS", "-1")
+	_, err = ConfigurationFromEnv("APP_MYSQL")
+	go_test_.Equal(t, "max_idle_conns", configurationErrorField(err))
+}
+
+func TestConfigurationFromFile(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "mysql.yaml")
+	go_test_.Equal(t, nil, os.WriteFile(yamlFile, []byte(`
+host: db
+port: 3307
+username: root
+password: pass
+conn_max_lifetime: 1h
+conn_params:
+  charset: utf8mb4
+`), 0600))
+	c, err := ConfigurationFromFile(yamlFile)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "db", c.Host)
+	go_test_.Equal(t, 3307, c.Port)
+	go_test_.Equal(t, time.Hour, c.ConnMaxLifetime)
+	go_test_.Equal(t, "utf8mb4", c.ConnParams["charset"])
+
+	jsonFile := filepath.Join(dir, "mysql.json")
+	go_test_.Equal(t, nil, os.WriteFile(jsonFile, []byte(`{"host": "db", "port": 3307, "username": "root", "max_open_conns": 20}`), 0600))
+	c, err = ConfigurationFromFile(jsonFile)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, 3307, c.Port)
+	go_test_.Equal(t, 20, c.MaxOpenConns)
+
+	go_test_.Equal(t, nil, os.WriteFile(jsonFile, []byte(`{"host": "db", "prot": 3307, "username": "root"}`), 0600))
+	_, err = ConfigurationFromFile(jsonFile)
+	go_test_.Equal(t, "prot", configurationErrorField(err))
+
+	go_test_.Equal(t, nil, os.WriteFile(yamlFile, []byte("host: db\nusername: root\nconn_max_lifetime: 10\n"), 0600))
+	_, err = ConfigurationFromFile(yamlFile)
+	go_test_.Equal(t, "conn_max_lifetime", configurationErrorField(err))
+}
+
+func TestConfigurationFromDSN_RoundTrip(t *testing.T) {
+	c, err := ConfigurationFromDSN("root:p?x@tcp(db:3306)/test?time_zone=%27%2B08%3A00%27&loc=Asia%2FShanghai")
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "p?x", c.Password)
+	go_test_.Equal(t, map[string]string{
+		"time_zone": "%27%2B08%3A00%27",
+		"loc":       "Asia%2FShanghai",
+	}, c.ConnParams)
+
+	// 按 openDb 的方式重新拼成 DSN，驱动解析出来的值不变
+	config, err := mysql.ParseDSN("root:p?x@tcp(db:3306)/test?" + buildConnParams(c.ConnParams))
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "'+08:00'", config.Params["time_zone"])
+	go_test_.Equal(t, "Asia/Shanghai", config.Loc.String())
+
+	c, err = ConfigurationFromDSN("root:p?x@tcp(db:3306)/test")
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, 0, len(c.ConnParams))
+}
@@ -0,0 +1,384 @@
+package go_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	i_logger "github.com/pefish/go-interface/i-logger"
+	"github.com/pkg/errors"
+)
+
+var (
+	DEFAULT_FAILOVER_CHECK_INTERVAL  = 3 * time.Second
+	DEFAULT_CONNECT_RETRY_BASE_DELAY = 500 * time.Millisecond
+	DEFAULT_CONNECT_RETRY_MAX_DELAY  = 10 * time.Second
+)
+
+// connector 为连接池创建物理连接。配置了多个 host 时只连接当前可写的 host，
+// 当前 host 连不上或者变成只读时切换到下一个可写的 host，旧 host 上的连接归还连接池时会被丢弃
+type connector struct {
+	hosts      []string
+	connectors []driver.Connector
+	current    int32  // 当前使用的 host 下标
+	generation uint64 // 每切换一次 host 加 1
+	lock       sync.Mutex
+	stop       chan struct{}
+	logger     i_logger.ILogger
+
+	tlsConfigName string // 注册到驱动的 TLS 配置，关闭时注销
+	dialName      string // 注册到驱动的自定义 dial 函数，关闭时注销
+
+	initStatements []string // 新连接交给连接池之前执行
+
+	waitReachable bool          // 懒连接模式
+	retryTimeout  time.Duration // 懒连接模式下等待数据库可用的最长时间，0 表示只受 ctx 限制
+	reachable     int32
+}
+
+func newConnector(config *mysql.Config, hosts []string, logger i_logger.ILogger) (*connector, error) {
+	c := &connector{
+		hosts:  hosts,
+		logger: logger,
+	}
+	for _, host := range hosts {
+		hostConfig := config.Clone()
+		hostConfig.Addr = host
+		if hostConfig.TLSConfig != "" {
+			// 重新按名字生成 TLS 配置，ServerName 默认值才会是当前 host
+			hostConfig.TLS = nil
+		}
+		hostConnector, err := mysql.NewConnector(hostConfig)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		c.connectors = append(c.connectors, hostConnector)
+	}
+	return c, nil
+}
+
+// registerDialContext 以唯一的名字注册 dial 函数，返回的名字作为连接的 network
+func registerDialContext(network string, dial DialContextFunc) string {
+	name := `go-mysql-` + uuid.New().String()
+	mysql.RegisterDialContext(name, func(ctx context.Context, addr string) (net.Conn, error) {
+		return dial(ctx, network, addr)
+	})
+	return name
+}
+
+func (c *connector) multiHost() bool {
+	return len(c.hosts) > 1
+}
+
+func (c *connector) currentHost() string {
+	return c.hosts[atomic.LoadInt32(&c.current)]
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if !c.waitReachable || atomic.LoadInt32(&c.reachable) == 1 {
+		return c.connect(ctx)
+	}
+
+	// 懒连接模式下第一次连接成功之前，连不上就等待数据库可用
+	var deadline time.Time
+	if c.retryTimeout > 0 {
+		deadline = time.Now().Add(c.retryTimeout)
+	}
+	var conn driver.Conn
+	err := retryConnect(ctx, deadline, c.logger, c.currentHost, func() (err error) {
+		conn, err = c.connect(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	atomic.StoreInt32(&c.reachable, 1)
+	return conn, nil
+}
+
+func (c *connector) connect(ctx context.Context) (driver.Conn, error) {
+	index := atomic.LoadInt32(&c.current)
+	generation := atomic.LoadUint64(&c.generation)
+	conn, err := c.connectWritable(ctx, int(index))
+	if err != nil && c.multiHost() {
+		c.logger.Warn(fmt.Sprintf(`mysql primary unavailable. url: %s, err: %v`, c.hosts[index], err))
+		index, err = c.failover(ctx, index)
+		if err != nil {
+			return nil, err
+		}
+		generation = atomic.LoadUint64(&c.generation)
+		conn, err = c.connectWritable(ctx, int(index))
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = c.initConn(ctx, conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &failoverConn{
+		Conn:       conn,
+		connector:  c,
+		generation: generation,
+	}, nil
+}
+
+// initConn 执行会话初始化语句，保证连接池里所有连接的会话设置一致
+func (c *connector) initConn(ctx context.Context, conn driver.Conn) error {
+	if len(c.initStatements) == 0 {
+		return nil
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return errors.New(`Connection does not support exec.`)
+	}
+	for _, statement := range c.initStatements {
+		_, err := execer.ExecContext(ctx, statement, nil)
+		if err != nil {
+			return errors.Wrapf(err, `Init statement <%s> failed.`, statement)
+		}
+	}
+	return nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &mysql.MySQLDriver{}
+}
+
+// connectWritable 多个 host 时连接后检查是否可写
+func (c *connector) connectWritable(ctx context.Context, index int) (driver.Conn, error) {
+	conn, err := c.connectors[index].Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !c.multiHost() {
+		return conn, nil
+	}
+	readOnly, err := queryReadOnly(ctx, conn)
+	if err == nil && readOnly {
+		err = errors.Errorf(`Host %s is read only.`, c.hosts[index])
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// failover 从 failed 的下一个 host 开始按顺序找可写的 host。其他协程已经切换过的话直接使用切换后的 host
+func (c *connector) failover(ctx context.Context, failed int32) (int32, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	current := atomic.LoadInt32(&c.current)
+	if current != failed {
+		return current, nil
+	}
+	for i := 1; i < len(c.hosts); i++ {
+		index := (int(failed) + i) % len(c.hosts)
+		conn, err := c.connectWritable(ctx, index)
+		if err != nil {
+			c.logger.Warn(fmt.Sprintf(`mysql host not writable. url: %s, err: %v`, c.hosts[index], err))
+			continue
+		}
+		_ = conn.Close()
+		atomic.StoreInt32(&c.current, int32(index))
+		atomic.AddUint64(&c.generation, 1)
+		c.logger.Warn(fmt.Sprintf(`mysql primary switched. from: %s, to: %s`, c.hosts[failed], c.hosts[index]))
+		return int32(index), nil
+	}
+	return failed, errors.New(`No writable mysql host.`)
+}
+
+// check 检查当前 host 是否还可写，不可写则切换
+func (c *connector) check(ctx context.Context) {
+	index := atomic.LoadInt32(&c.current)
+	conn, err := c.connectWritable(ctx, int(index))
+	if err == nil {
+		_ = conn.Close()
+		return
+	}
+	c.logger.Warn(fmt.Sprintf(`mysql primary unavailable. url: %s, err: %v`, c.hosts[index], err))
+	_, err = c.failover(ctx, index)
+	if err != nil {
+		c.logger.Error(err)
+	}
+}
+
+func (c *connector) startMonitor(interval time.Duration) {
+	c.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				c.check(ctx)
+				cancel()
+			}
+		}
+	}(c.stop)
+}
+
+func (c *connector) close() {
+	if c == nil {
+		return
+	}
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	if c.tlsConfigName != "" {
+		mysql.DeregisterTLSConfig(c.tlsConfigName)
+		c.tlsConfigName = ""
+	}
+	if c.dialName != "" {
+		mysql.DeregisterDialContext(c.dialName)
+		c.dialName = ""
+	}
+}
+
+func queryReadOnly(ctx context.Context, conn driver.Conn) (bool, error) {
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return false, errors.New(`Connection does not support query.`)
+	}
+	rows, err := queryer.QueryContext(ctx, `select @@global.read_only`, nil)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	defer rows.Close()
+	values := make([]driver.Value, 1)
+	err = rows.Next(values)
+	if err == io.EOF {
+		return false, errors.New(`Read only status not found.`)
+	}
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	var readOnly int64
+	switch v := values[0].(type) {
+	case int64:
+		readOnly = v
+	case []byte:
+		readOnly, err = strconv.ParseInt(string(v), 10, 64)
+	default:
+		err = errors.Errorf(`Unexpected read only value %v.`, v)
+	}
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return readOnly != 0, nil
+}
+
+// failoverConn 切换 host 之后，旧 host 上的连接不再复用
+type failoverConn struct {
+	driver.Conn
+	connector  *connector
+	generation uint64
+}
+
+func (c *failoverConn) stale() bool {
+	return c.generation != atomic.LoadUint64(&c.connector.generation)
+}
+
+func (c *failoverConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *failoverConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *failoverConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *failoverConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *failoverConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *failoverConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *failoverConn) ResetSession(ctx context.Context) error {
+	if c.stale() {
+		return driver.ErrBadConn
+	}
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *failoverConn) IsValid() bool {
+	if c.stale() {
+		return false
+	}
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// retryConnect 按指数退避重试 fn，直到成功、ctx 结束或者超过 deadline（零值表示没有 deadline）
+func retryConnect(
+	ctx context.Context,
+	deadline time.Time,
+	logger i_logger.ILogger,
+	address func() string,
+	fn func() error,
+) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		delay := retryDelay(DEFAULT_CONNECT_RETRY_BASE_DELAY, DEFAULT_CONNECT_RETRY_MAX_DELAY, attempt)
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		logger.Warn(fmt.Sprintf(`mysql connect failed, retrying. url: %s, attempt: %d, delay: %s, err: %v`, address(), attempt, delay, err))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
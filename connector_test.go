@@ -0,0 +1,175 @@
+package go_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	i_logger "github.com/pefish/go-interface/i-logger"
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	go_test_ "github.com/pefish/go-test"
+)
+
+func newFakeHost(readOnly int64) *fakeRecorder {
+	return &fakeRecorder{
+		rows: func(query string) ([]string, [][]driver.Value) {
+			return []string{"@@global.read_only"}, [][]driver.Value{{readOnly}}
+		},
+	}
+}
+
+func TestConnector_Failover(t *testing.T) {
+	hostA := newFakeHost(0)
+	hostB := newFakeHost(0)
+	hostC := newFakeHost(0)
+	c := &connector{
+		hosts: []string{"a:3306", "b:3306", "c:3306"},
+		connectors: []driver.Connector{
+			&fakeConnector{recorder: hostA},
+			&fakeConnector{recorder: hostB},
+			&fakeConnector{recorder: hostC},
+		},
+		logger: &i_logger.DefaultLogger,
+	}
+
+	conn, err := c.Connect(context.Background())
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "a:3306", c.currentHost())
+	go_test_.Equal(t, true, conn.(driver.Validator).IsValid())
+
+	// a 变成只读，b 连不上，切换到 c
+	hostA.rows = newFakeHost(1).rows
+	hostB.connectErr = errors.New("connection refused")
+	c.check(context.Background())
+	go_test_.Equal(t, "c:3306", c.currentHost())
+	go_test_.Equal(t, false, conn.(driver.Validator).IsValid())
+	go_test_.Equal(t, driver.ErrBadConn, conn.(driver.SessionResetter).ResetSession(context.Background()))
+
+	conn, err = c.Connect(context.Background())
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, true, conn.(driver.Validator).IsValid())
+
+	// c 连不上，从下一个开始找，回到 a
+	hostA.rows = newFakeHost(0).rows
+	hostC.connectErr = errors.New("connection refused")
+	_, err = c.Connect(context.Background())
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "a:3306", c.currentHost())
+
+	hostA.connectErr = errors.New("connection refused")
+	_, err = c.Connect(context.Background())
+	go_test_.NotEqual(t, nil, err)
+}
+
+type flakyConnector struct {
+	fakeConnector
+	failures int
+}
+
+func (c *flakyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.failures > 0 {
+		c.failures--
+		return nil, errors.New("connection refused")
+	}
+	return c.fakeConnector.Connect(ctx)
+}
+
+func TestConnector_WaitReachable(t *testing.T) {
+	baseDelay, maxDelay := DEFAULT_CONNECT_RETRY_BASE_DELAY, DEFAULT_CONNECT_RETRY_MAX_DELAY
+	DEFAULT_CONNECT_RETRY_BASE_DELAY, DEFAULT_CONNECT_RETRY_MAX_DELAY = time.Millisecond, 4*time.Millisecond
+	defer func() {
+		DEFAULT_CONNECT_RETRY_BASE_DELAY, DEFAULT_CONNECT_RETRY_MAX_DELAY = baseDelay, maxDelay
+	}()
+
+	flaky := &flakyConnector{fakeConnector: fakeConnector{recorder: &fakeRecorder{}}, failures: 3}
+	c := &connector{
+		hosts:         []string{"a:3306"},
+		connectors:    []driver.Connector{flaky},
+		logger:        &i_logger.DefaultLogger,
+		waitReachable: true,
+	}
+	_, err := c.Connect(context.Background())
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, 0, flaky.failures)
+
+	// 连上过之后不再等待
+	flaky.failures = 1
+	_, err = c.Connect(context.Background())
+	go_test_.NotEqual(t, nil, err)
+
+	flaky.failures = 1000
+	c = &connector{
+		hosts:         []string{"a:3306"},
+		connectors:    []driver.Connector{flaky},
+		logger:        &i_logger.DefaultLogger,
+		waitReachable: true,
+		retryTimeout:  20 * time.Millisecond,
+	}
+	_, err = c.Connect(context.Background())
+	go_test_.NotEqual(t, nil, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	c.retryTimeout = 0
+	_, err = c.Connect(ctx)
+	go_test_.NotEqual(t, nil, err)
+}
+
+func TestConnector_InitStatements(t *testing.T) {
+	recorder := &fakeRecorder{}
+	c := &connector{
+		hosts:          []string{"a:3306"},
+		connectors:     []driver.Connector{&fakeConnector{recorder: recorder}},
+		logger:         &i_logger.DefaultLogger,
+		initStatements: []string{"set time_zone = '+00:00'", "set names utf8mb4"},
+	}
+	_, err := c.Connect(context.Background())
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, []string{"set time_zone = '+00:00'", "set names utf8mb4"}, recorder.Stmts())
+
+	recorder.execErr = func(query string) error {
+		if query == "set names utf8mb4" {
+			return errors.New("unknown character set")
+		}
+		return nil
+	}
+	_, err = c.Connect(context.Background())
+	go_test_.Equal(t, "Init statement <set names utf8mb4> failed.: unknown character set", err.Error())
+}
+
+func TestMysqlType_ConnectDialContext(t *testing.T) {
+	dialErr := errors.New("dial refused")
+	var networks, addresses []string
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		networks = append(networks, network)
+		addresses = append(addresses, address)
+		return nil, dialErr
+	}
+
+	mc := NewMysqlInstance(&i_logger.DefaultLogger)
+	err := mc.Connect(context.Background(), &Configuration{
+		Configuration: t_mysql.Configuration{
+			Username: "root",
+		},
+		Socket:      "/var/run/mysqld/mysqld.sock",
+		DialContext: dial,
+	})
+	go_test_.Equal(t, true, errors.Is(err, dialErr))
+	go_test_.Equal(t, "unix", networks[0])
+	go_test_.Equal(t, "/var/run/mysqld/mysqld.sock", addresses[0])
+
+	networks, addresses = nil, nil
+	err = mc.Connect(context.Background(), &Configuration{
+		Configuration: t_mysql.Configuration{
+			Host:     "db",
+			Username: "root",
+		},
+		DialContext: dial,
+	})
+	go_test_.Equal(t, true, errors.Is(err, dialErr))
+	go_test_.Equal(t, "tcp", networks[0])
+	go_test_.Equal(t, "db:3306", addresses[0])
+}
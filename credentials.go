@@ -0,0 +1,48 @@
+package go_mysql
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+// CredentialsProvider 每次建立物理连接时调用，返回的用户名为空则使用配置里的用户名。
+// 密码轮换后新连接使用新密码，旧连接到达 ConnMaxLifetime 后自然淘汰
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (username string, password string, err error)
+}
+
+type CredentialsProviderFunc func(ctx context.Context) (username string, password string, err error)
+
+func (f CredentialsProviderFunc) Credentials(ctx context.Context) (string, string, error) {
+	return f(ctx)
+}
+
+// PasswordFileProvider 每次建立连接时从文件读取密码，去掉首尾空白
+type PasswordFileProvider struct {
+	Username string
+	Path     string
+}
+
+func (p *PasswordFileProvider) Credentials(ctx context.Context) (string, string, error) {
+	content, err := os.ReadFile(p.Path)
+	if err != nil {
+		return ``, ``, errors.WithStack(err)
+	}
+	return p.Username, strings.TrimSpace(string(content)), nil
+}
+
+func applyCredentials(ctx context.Context, provider CredentialsProvider, config *mysql.Config) error {
+	username, password, err := provider.Credentials(ctx)
+	if err != nil {
+		return errors.Wrap(err, `Get mysql credentials failed.`)
+	}
+	if username != "" {
+		config.User = username
+	}
+	config.Passwd = password
+	return nil
+}
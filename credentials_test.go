@@ -0,0 +1,67 @@
+package go_mysql
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	i_logger "github.com/pefish/go-interface/i-logger"
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestPasswordFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	go_test_.Equal(t, nil, os.WriteFile(path, []byte("first\n"), 0600))
+	provider := &PasswordFileProvider{Username: "app", Path: path}
+	config := mysql.NewConfig()
+	config.User = "root"
+
+	err := applyCredentials(context.Background(), provider, config)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "app", config.User)
+	go_test_.Equal(t, "first", config.Passwd)
+
+	// 轮换之后读到新密码
+	go_test_.Equal(t, nil, os.WriteFile(path, []byte("second"), 0600))
+	err = applyCredentials(context.Background(), provider, config)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "second", config.Passwd)
+
+	// 用户名为空保留配置里的用户名
+	config.User = "root"
+	err = applyCredentials(context.Background(), CredentialsProviderFunc(func(ctx context.Context) (string, string, error) {
+		return "", "token", nil
+	}), config)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "root", config.User)
+	go_test_.Equal(t, "token", config.Passwd)
+}
+
+func TestMysqlType_ConnectCredentials(t *testing.T) {
+	providerErr := errors.New("token expired")
+	calls := 0
+	dials := 0
+	mc := NewMysqlInstance(&i_logger.DefaultLogger)
+	err := mc.Connect(context.Background(), &Configuration{
+		Configuration: t_mysql.Configuration{
+			Host:     "db",
+			Username: "root",
+		},
+		Credentials: CredentialsProviderFunc(func(ctx context.Context) (string, string, error) {
+			calls++
+			return "", "", providerErr
+		}),
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dials++
+			return nil, errors.New("dial refused")
+		},
+	})
+	go_test_.Equal(t, true, errors.Is(err, providerErr))
+	go_test_.Equal(t, true, calls > 0)
+	go_test_.Equal(t, 0, dials)
+}
@@ -9,6 +9,7 @@ require (
 	github.com/pefish/go-test v0.0.4
 	github.com/pefish/go-time v0.3.4
 	github.com/pkg/errors v0.9.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,79 @@
+package go_mysql
+
+import (
+	"fmt"
+	"sync"
+)
+
+type txHooks struct {
+	lock       sync.Mutex
+	onCommit   []func()
+	onRollback []func()
+}
+
+func (h *txHooks) moveTo(target *txHooks) {
+	h.lock.Lock()
+	onCommit, onRollback := h.onCommit, h.onRollback
+	h.onCommit, h.onRollback = nil, nil
+	h.lock.Unlock()
+
+	target.lock.Lock()
+	defer target.lock.Unlock()
+	target.onCommit = append(target.onCommit, onCommit...)
+	target.onRollback = append(target.onRollback, onRollback...)
+}
+
+func (h *txHooks) take() (onCommit []func(), onRollback []func()) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	onCommit, onRollback = h.onCommit, h.onRollback
+	h.onCommit, h.onRollback = nil, nil
+	return
+}
+
+func (h *txHooks) fireCommit(mc *MysqlType) {
+	onCommit, _ := h.take()
+	for _, fn := range onCommit {
+		mc.runHook(`commit`, fn)
+	}
+}
+
+func (h *txHooks) fireRollback(mc *MysqlType) {
+	_, onRollback := h.take()
+	for _, fn := range onRollback {
+		mc.runHook(`rollback`, fn)
+	}
+}
+
+// runHook 事务已经结束，回调 panic 不能再影响调用方，只记录日志
+func (mc *MysqlType) runHook(event string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			mc.logger.Error(fmt.Sprintf(`%s%s hook panic. err: %v`, mc.txInfo(), event, r))
+		}
+	}()
+	fn()
+}
+
+// OnCommit 注册事务提交成功后执行的回调，按注册顺序执行。
+// 嵌套事务的回调在最外层事务提交后才执行；不在事务中时立即执行
+func (mc *MysqlType) OnCommit(fn func()) {
+	if mc.tx == nil {
+		fn()
+		return
+	}
+	mc.hooks.lock.Lock()
+	defer mc.hooks.lock.Unlock()
+	mc.hooks.onCommit = append(mc.hooks.onCommit, fn)
+}
+
+// OnRollback 注册事务回滚成功后执行的回调，按注册顺序执行。
+// 嵌套事务回滚到 savepoint 时立即执行；不在事务中时永远不会执行
+func (mc *MysqlType) OnRollback(fn func()) {
+	if mc.tx == nil {
+		return
+	}
+	mc.hooks.lock.Lock()
+	defer mc.hooks.lock.Unlock()
+	mc.hooks.onRollback = append(mc.hooks.onRollback, fn)
+}
@@ -0,0 +1,50 @@
+package go_mysql
+
+import (
+	"testing"
+
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestMysqlType_OnCommit(t *testing.T) {
+	mysql, _ := newFakeMysql()
+	events := make([]string, 0)
+
+	tx_, err := mysql.Begin()
+	go_test_.Equal(t, nil, err)
+	tx := tx_.(*MysqlType)
+	tx.OnCommit(func() { events = append(events, "commit 1") })
+	tx.OnRollback(func() { events = append(events, "rollback 1") })
+
+	nested_, err := tx.Begin()
+	go_test_.Equal(t, nil, err)
+	nested := nested_.(*MysqlType)
+	nested.OnCommit(func() { events = append(events, "commit 2") })
+	go_test_.Equal(t, nil, nested.Commit())
+
+	nested_, err = tx.Begin()
+	go_test_.Equal(t, nil, err)
+	nested = nested_.(*MysqlType)
+	nested.OnCommit(func() { events = append(events, "commit 3") })
+	nested.OnRollback(func() { events = append(events, "rollback 3") })
+	go_test_.Equal(t, nil, nested.Rollback())
+	go_test_.Equal(t, []string{"rollback 3"}, events)
+
+	tx.OnCommit(func() { panic("boom") })
+	go_test_.Equal(t, nil, tx.Commit())
+	go_test_.Equal(t, []string{"rollback 3", "commit 1", "commit 2"}, events)
+
+	events = make([]string, 0)
+	tx_, err = mysql.Begin()
+	go_test_.Equal(t, nil, err)
+	tx = tx_.(*MysqlType)
+	tx.OnCommit(func() { events = append(events, "commit") })
+	tx.OnRollback(func() { events = append(events, "rollback") })
+	go_test_.Equal(t, nil, tx.Rollback())
+	go_test_.Equal(t, []string{"rollback"}, events)
+
+	events = make([]string, 0)
+	mysql.OnCommit(func() { events = append(events, "commit") })
+	mysql.OnRollback(func() { events = append(events, "rollback") })
+	go_test_.Equal(t, []string{"commit"}, events)
+}
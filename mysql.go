@@ -1,10 +1,17 @@
 package go_mysql
 
 import (
+	"context"
 	sql2 "database/sql"
 	"fmt"
+	"math"
+	"net"
 	"reflect"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,7 +19,7 @@ import (
 	go_time "github.com/pefish/go-time"
 	"github.com/pkg/errors"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	i_logger "github.com/pefish/go-interface/i-logger"
 	i_mysql "github.com/pefish/go-interface/i-mysql"
 	t_mysql "github.com/pefish/go-interface/t-mysql"
@@ -27,21 +34,53 @@ var (
 )
 
 var ErrorNoAffectedRows error = errors.New("No affected rows.")
+var ErrTxDone error = errors.New("Transaction has already been committed or rolled back.")
+var ErrNotInTransaction error = errors.New("Not in transaction.")
+var ErrTxConcurrentUse error = errors.New("Transaction is being used by another goroutine.")
+var ErrXaBranch error = errors.New("XA branch can only be committed or rolled back by its coordinator.")
 
 // ----------------------------- MysqlClass -----------------------------
 
 type MysqlType struct {
-	db      *sqlx.DB
-	txId    string
-	tx      *sqlx.Tx
-	tagName string
-	logger  i_logger.ILogger
+	db           *sqlx.DB // 主库
+	connector    *connector
+	replicas     []*Replica
+	balancer     Balancer
+	txId         string
+	tx           *sqlx.Tx
+	savepoint    string  // 非空表示这是一个嵌套事务，对应的 savepoint 名
+	savepointSeq *uint64 // 同一个事务下的所有嵌套事务共享
+	parent       *MysqlType
+	hooks        *txHooks
+	state        int32  // 事务状态，txStateActive 等
+	busy         *int32 // 同一个事务（包括嵌套事务）共享一个连接，不能并发使用
+	xid          string // 非空表示这是一个 XA 分支，由 XaCoordinator 负责提交或回滚
+	xaState      int
+	beganAt      time.Time
+	beginStack   []byte
+	leakWarned   int32
+	abortCtx     context.Context // 看门狗强制回滚时取消，中断正在执行的语句，嵌套事务共享
+	abort        context.CancelFunc
+	txTracker    *txTracker
+	watchdogLock sync.Mutex
+	watchdogStop chan struct{}
+	// 连接池配置
+	poolLock        sync.Mutex
+	poolSettings    PoolSettings
+	poolWatcherStop chan struct{}
+	// 从库延迟检查以及 read-your-writes
+	lagMonitorLock  sync.Mutex
+	lagMonitorStop  chan struct{}
+	gtidWaitTimeout time.Duration
+	tagName         string
+	logger          i_logger.ILogger
 }
 
 func NewMysqlInstance(logger i_logger.ILogger) *MysqlType {
 	return &MysqlType{
-		tagName: `json`,
-		logger:  logger,
+		txTracker: &txTracker{},
+		tagName:   `json`,
+		logger:    logger,
 	}
 }
 
@@ -50,6 +89,19 @@ func (mc *MysqlType) TagName() string {
 }
 
 func (mc *MysqlType) Close() {
+	mc.StopTxWatchdog()
+	mc.StopReplicaLagMonitor()
+	mc.StopPoolSettingsWatcher()
+	for _, replica := range mc.replicas {
+		err := replica.db.Close()
+		if err != nil {
+			mc.logger.Error(err)
+		}
+		replica.connector.close()
+	}
+	if mc.connector != nil {
+		mc.connector.close()
+	}
 	if mc.db != nil {
 		err := mc.db.Close()
 		if err != nil {
@@ -58,8 +110,9 @@ func (mc *MysqlType) Close() {
 			mc.logger.Info(`mysql close succeed.`)
 		}
 	}
-	if mc.tx != nil {
-		err := mc.tx.Rollback()
+	if mc.tx != nil && !mc.txDone() {
+		mc.logger.Warn(fmt.Sprintf(`%sclosing an active transaction, rollback.`, mc.txInfo()))
+		err := mc.Rollback()
 		if err != nil {
 			mc.logger.Error(err)
 		}
@@ -67,6 +120,23 @@ func (mc *MysqlType) Close() {
 }
 
 func (mc *MysqlType) ConnectWithConfiguration(configuration t_mysql.Configuration) error {
+	return mc.ConnectWithConfigurationContext(context.Background(), configuration)
+}
+
+func (mc *MysqlType) ConnectWithConfigurationContext(ctx context.Context, configuration t_mysql.Configuration) error {
+	return mc.Connect(ctx, &Configuration{
+		Configuration: configuration,
+	})
+}
+
+// openDb hosts 为空时使用 configuration.Host，host 没有带端口时使用 configuration.Port。options 中是主从库共用的连接选项
+func (mc *MysqlType) openDb(
+	ctx context.Context,
+	configuration t_mysql.Configuration,
+	hosts []string,
+	socket string,
+	options *Configuration,
+) (*sqlx.DB, *connector, error) {
 	var port = DEFAULT_PORT
 	if configuration.Port != 0 {
 		port = configuration.Port
@@ -75,56 +145,122 @@ func (mc *MysqlType) ConnectWithConfiguration(configuration t_mysql.Configuratio
 	if configuration.Database != "" {
 		database = configuration.Database
 	}
-	var maxOpenConns = DEFAULT_MAX_OPEN_CONNS
-	if configuration.MaxOpenConns != 0 {
-		maxOpenConns = configuration.MaxOpenConns
-	}
-	var maxIdleConns = DEFAULT_MAX_IDLE_CONNS
-	if configuration.MaxIdleConns != 0 {
-		maxIdleConns = configuration.MaxIdleConns
-	}
-	connMaxLifetime := DEFAULT_CONN_MAX_LIFTTIME
-	if configuration.ConnMaxLifetime != 0 {
-		connMaxLifetime = configuration.ConnMaxLifetime
-	}
+	poolSettings := poolSettingsFrom(configuration, options.ConnMaxIdleTime)
 
-	address := fmt.Sprintf(`%s:%d`, configuration.Host, port)
+	network := `tcp`
+	var addresses []string
+	if socket != "" {
+		network = `unix`
+		addresses = []string{socket}
+	} else {
+		if len(hosts) == 0 {
+			hosts = []string{configuration.Host}
+		}
+		addresses = make([]string, 0, len(hosts))
+		for _, host := range hosts {
+			if _, _, err := net.SplitHostPort(host); err == nil {
+				addresses = append(addresses, host)
+			} else {
+				addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(port)))
+			}
+		}
+	}
+	address := fmt.Sprintf(`%s(%s)`, network, strings.Join(addresses, `,`))
 	mc.logger.Info(fmt.Sprintf(`mysql connecting... url: %s`, address))
 
-	connParamsStr := "parseTime=true&multiStatements=true&loc=UTC"
-	if configuration.ConnParams != nil {
-		for k, v := range configuration.ConnParams {
-			connParamsStr += fmt.Sprintf("&%s=%s", k, v)
+	connParamsStr := buildConnParams(configuration.ConnParams)
+	tlsConfigName := ``
+	if options.TLS != nil {
+		var err error
+		tlsConfigName, err = options.TLS.register()
+		if err != nil {
+			return nil, nil, err
+		}
+		connParamsStr += fmt.Sprintf("&tls=%s", tlsConfigName)
+		if options.TLS.AllowFallbackToPlaintext {
+			connParamsStr += "&allowFallbackToPlaintext=true"
 		}
 	}
 	connUrl := fmt.Sprintf(
-		`%s:%s@tcp(%s)/%s?%s`,
+		`%s:%s@%s(%s)/%s?%s`,
 		configuration.Username,
 		configuration.Password,
-		address,
+		network,
+		addresses[0],
 		database,
 		connParamsStr,
 	)
-	db, err := sqlx.Connect(`mysql`, connUrl)
+	config, err := mysql.ParseDSN(connUrl)
 	if err != nil {
-		return err
+		mysql.DeregisterTLSConfig(tlsConfigName)
+		return nil, nil, errors.WithStack(err)
+	}
+	if options.Credentials != nil {
+		provider := options.Credentials
+		err = config.Apply(mysql.BeforeConnect(func(ctx context.Context, config *mysql.Config) error {
+			return applyCredentials(ctx, provider, config)
+		}))
+		if err != nil {
+			mysql.DeregisterTLSConfig(tlsConfigName)
+			return nil, nil, errors.WithStack(err)
+		}
+	}
+	dialName := ``
+	if options.DialContext != nil {
+		dialName = registerDialContext(network, options.DialContext)
+		config.Net = dialName
 	}
+	connector, err := newConnector(config, addresses, mc.logger)
+	if err != nil {
+		mysql.DeregisterTLSConfig(tlsConfigName)
+		mysql.DeregisterDialContext(dialName)
+		return nil, nil, err
+	}
+	connector.tlsConfigName = tlsConfigName
+	connector.dialName = dialName
+	connector.initStatements = options.InitStatements
+	connector.waitReachable = options.LazyConnect
+	connector.retryTimeout = options.ConnectRetryTimeout
+	db := sqlx.NewDb(sql2.OpenDB(connector), `mysql`)
 	db.SetTagName(mc.tagName)
-	mc.logger.Info(fmt.Sprintf(`mysql connect succeed. url: %s`, address))
-	db.DB.SetMaxOpenConns(maxOpenConns)       // 用于设置最大打开的连接数，默认值为0表示不限制
-	db.DB.SetMaxIdleConns(maxIdleConns)       // 用于设置闲置的连接数
-	db.DB.SetConnMaxLifetime(connMaxLifetime) // 设置一个超时时间，时间小于数据库的超时时间即可
-	mc.db = db
+	if options.LazyConnect {
+		mc.logger.Info(fmt.Sprintf(`mysql lazy connect, connect on first use. url: %s`, address))
+	} else {
+		// ConnectRetryTimeout 为 0 时 deadline 已过，失败一次就返回
+		deadline := time.Now().Add(options.ConnectRetryTimeout)
+		err = retryConnect(ctx, deadline, mc.logger, connector.currentHost, func() error {
+			return db.PingContext(ctx)
+		})
+		if err != nil {
+			_ = db.Close()
+			connector.close()
+			return nil, nil, err
+		}
+		mc.logger.Info(fmt.Sprintf(`mysql connect succeed. url: %s`, connector.currentHost()))
+	}
+	poolSettings.apply(db.DB)
 
-	return nil
+	return db, connector, nil
 }
 
-func (mc *MysqlType) printDebugInfo(sql string, values interface{}) {
-	txInfo := ``
-	if mc.tx != nil {
-		txInfo = fmt.Sprintf(`[transaction id: %s] `, mc.txId)
+// buildConnParams ConnParams 的值原样拼进 DSN，包含特殊字符的值（比如 loc=Asia%2FShanghai）需要调用方自己转义
+func buildConnParams(connParams map[string]string) string {
+	connParamsStr := "parseTime=true&multiStatements=true&loc=UTC"
+	for k, v := range connParams {
+		connParamsStr += fmt.Sprintf("&%s=%s", k, v)
 	}
-	mc.logger.DebugF("%s%s, %v\n", txInfo, sql, values)
+	return connParamsStr
+}
+
+func (mc *MysqlType) txInfo() string {
+	if mc.tx == nil {
+		return ``
+	}
+	return fmt.Sprintf(`[transaction id: %s] `, mc.txId)
+}
+
+func (mc *MysqlType) printDebugInfo(sql string, values interface{}) {
+	mc.logger.DebugF("%s%s, %v\n", mc.txInfo(), sql, values)
 }
 
 func (mc *MysqlType) RawSelect(
@@ -132,6 +268,16 @@ func (mc *MysqlType) RawSelect(
 	select_ string,
 	str string,
 	values ...interface{},
+) error {
+	return mc.RawSelectContext(context.Background(), dest, select_, str, values...)
+}
+
+func (mc *MysqlType) RawSelectContext(
+	ctx context.Context,
+	dest interface{},
+	select_ string,
+	str string,
+	values ...interface{},
 ) error {
 	sql := str
 	if select_ != "" {
@@ -143,7 +289,7 @@ func (mc *MysqlType) RawSelect(
 		)
 	}
 
-	err := mc.rawSelect(dest, sql, values...)
+	err := mc.rawSelect(ctx, dest, sql, values...)
 	if err != nil {
 		return err
 	}
@@ -158,6 +304,19 @@ func (mc *MysqlType) RawSelectFirst(
 ) (
 	notFound bool,
 	err error,
+) {
+	return mc.RawSelectFirstContext(context.Background(), dest, select_, str, values...)
+}
+
+func (mc *MysqlType) RawSelectFirstContext(
+	ctx context.Context,
+	dest interface{},
+	select_ string,
+	str string,
+	values ...interface{},
+) (
+	notFound bool,
+	err error,
 ) {
 	sql := str
 	if select_ != "" {
@@ -168,7 +327,7 @@ func (mc *MysqlType) RawSelectFirst(
 			str,
 		)
 	}
-	return mc.rawSelectFirst(dest, sql, values...)
+	return mc.rawSelectFirst(ctx, dest, sql, values...)
 }
 
 func (mc *MysqlType) processValues(sql string, values []interface{}) (string, []interface{}, error) {
@@ -191,7 +350,11 @@ func (mc *MysqlType) processValues(sql string, values []interface{}) (string, []
 }
 
 func (mc *MysqlType) MustRawExec(sql string, values ...interface{}) uint64 {
-	lastInsertId, err := mc.RawExec(sql, values...)
+	return mc.MustRawExecContext(context.Background(), sql, values...)
+}
+
+func (mc *MysqlType) MustRawExecContext(ctx context.Context, sql string, values ...interface{}) uint64 {
+	lastInsertId, err := mc.RawExecContext(ctx, sql, values...)
 	if err != nil {
 		panic(err)
 	}
@@ -202,17 +365,32 @@ func (mc *MysqlType) RawExec(sql string, values ...interface{}) (
 	lastInsertId uint64,
 	err error,
 ) {
+	return mc.RawExecContext(context.Background(), sql, values...)
+}
+
+func (mc *MysqlType) RawExecContext(ctx context.Context, sql string, values ...interface{}) (
+	lastInsertId uint64,
+	err error,
+) {
+	mc = mc.ambientTx(ctx)
 	sql, values, err = mc.processValues(sql, values)
 	mc.printDebugInfo(sql, values)
 	if err != nil {
 		return 0, err
 	}
 
+	leave, err := mc.enterTx()
+	if err != nil {
+		return 0, err
+	}
+	defer leave()
+	ctx, stop := mc.abortableContext(ctx)
+	defer stop()
 	var result sql2.Result
 	if mc.tx != nil {
-		result, err = mc.tx.Exec(sql, values...)
+		result, err = mc.tx.ExecContext(ctx, sql, values...)
 	} else {
-		result, err = mc.db.Exec(sql, values...)
+		result, err = mc.db.ExecContext(ctx, sql, values...)
 	}
 	if err != nil {
 		return 0, errors.WithStack(err)
@@ -228,6 +406,9 @@ func (mc *MysqlType) RawExec(sql string, values ...interface{}) (
 	if rowsAffected == 0 {
 		return 0, ErrorNoAffectedRows
 	}
+	if mc.tx == nil {
+		mc.captureGtid(ctx, mc.db)
+	}
 	return uint64(lastInsertId_), nil
 }
 
@@ -244,19 +425,28 @@ func (mc *MysqlType) replaceIfStar(dest interface{}, str string) string {
 }
 
 func (mc *MysqlType) rawSelect(
+	ctx context.Context,
 	dest interface{},
 	sql string,
 	values ...interface{},
 ) error {
+	mc = mc.ambientTx(ctx)
 	sql, values, err := mc.processValues(sql, values)
 	mc.printDebugInfo(sql, values)
 	if err != nil {
 		return err
 	}
+	leave, err := mc.enterTx()
+	if err != nil {
+		return err
+	}
+	defer leave()
+	ctx, stop := mc.abortableContext(ctx)
+	defer stop()
 	if mc.tx != nil {
-		err = mc.tx.Select(dest, sql, values...)
+		err = mc.tx.SelectContext(ctx, dest, sql, values...)
 	} else {
-		err = mc.db.Select(dest, sql, values...)
+		err = mc.readerDb(ctx).SelectContext(ctx, dest, sql, values...)
 	}
 	if err != nil {
 		return errors.WithStack(err)
@@ -267,6 +457,13 @@ func (mc *MysqlType) rawSelect(
 func (mc *MysqlType) Count(countParams *t_mysql.CountParams, values ...interface{}) (
 	count uint64,
 	err error,
+) {
+	return mc.CountContext(context.Background(), countParams, values...)
+}
+
+func (mc *MysqlType) CountContext(ctx context.Context, countParams *t_mysql.CountParams, values ...interface{}) (
+	count uint64,
+	err error,
 ) {
 	var countStruct struct {
 		Count uint64 `json:"count"`
@@ -283,7 +480,7 @@ func (mc *MysqlType) Count(countParams *t_mysql.CountParams, values ...interface
 		whereStr,
 	)
 
-	_, err = mc.rawSelectFirst(&countStruct, sql, paramArgs...)
+	_, err = mc.rawSelectFirst(ctx, &countStruct, sql, paramArgs...)
 	if err != nil {
 		return 0, err
 	}
@@ -294,6 +491,14 @@ func (mc *MysqlType) RawCount(sql string, values ...interface{}) (
 	count uint64,
 	err error,
 ) {
+	return mc.RawCountContext(context.Background(), sql, values...)
+}
+
+func (mc *MysqlType) RawCountContext(ctx context.Context, sql string, values ...interface{}) (
+	count uint64,
+	err error,
+) {
+	mc = mc.ambientTx(ctx)
 	var countStruct struct {
 		Count uint64 `json:"count"`
 	}
@@ -302,10 +507,17 @@ func (mc *MysqlType) RawCount(sql string, values ...interface{}) (
 	if err != nil {
 		return 0, err
 	}
+	leave, err := mc.enterTx()
+	if err != nil {
+		return 0, err
+	}
+	defer leave()
+	ctx, stop := mc.abortableContext(ctx)
+	defer stop()
 	if mc.tx != nil {
-		err = mc.tx.Select(&countStruct, sql, values...)
+		err = mc.tx.SelectContext(ctx, &countStruct, sql, values...)
 	} else {
-		err = mc.db.Select(&countStruct, sql, values...)
+		err = mc.readerDb(ctx).SelectContext(ctx, &countStruct, sql, values...)
 	}
 	if err != nil {
 		return 0, errors.WithStack(err)
@@ -319,6 +531,17 @@ func (mc *MysqlType) Sum(
 ) (
 	sum float64,
 	err error,
+) {
+	return mc.SumContext(context.Background(), sumParams, values...)
+}
+
+func (mc *MysqlType) SumContext(
+	ctx context.Context,
+	sumParams *t_mysql.SumParams,
+	values ...interface{},
+) (
+	sum float64,
+	err error,
 ) {
 	var sumStruct struct {
 		Sum *string `json:"sum"`
@@ -336,7 +559,7 @@ func (mc *MysqlType) Sum(
 		whereStr,
 	)
 
-	_, err = mc.rawSelectFirst(&sumStruct, sql, paramArgs...)
+	_, err = mc.rawSelectFirst(ctx, &sumStruct, sql, paramArgs...)
 	if err != nil {
 		return 0, err
 	}
@@ -353,13 +576,25 @@ func (mc *MysqlType) SelectFirst(
 ) (
 	notFound bool,
 	err error,
+) {
+	return mc.SelectFirstContext(context.Background(), dest, selectParams, values...)
+}
+
+func (mc *MysqlType) SelectFirstContext(
+	ctx context.Context,
+	dest interface{},
+	selectParams *t_mysql.SelectParams,
+	values ...interface{},
+) (
+	notFound bool,
+	err error,
 ) {
 	selectParams.Select = mc.replaceIfStar(dest, selectParams.Select)
 	sql, paramArgs, err := builder.buildSelectSql(selectParams, values...)
 	if err != nil {
 		return true, err
 	}
-	return mc.rawSelectFirst(dest, sql, paramArgs...)
+	return mc.rawSelectFirst(ctx, dest, sql, paramArgs...)
 }
 
 func (mc *MysqlType) SelectById(
@@ -368,6 +603,17 @@ func (mc *MysqlType) SelectById(
 ) (
 	notFound bool,
 	err error,
+) {
+	return mc.SelectByIdContext(context.Background(), dest, selectByIdParams)
+}
+
+func (mc *MysqlType) SelectByIdContext(
+	ctx context.Context,
+	dest interface{},
+	selectByIdParams *t_mysql.SelectByIdParams,
+) (
+	notFound bool,
+	err error,
 ) {
 	select_ := mc.replaceIfStar(dest, selectByIdParams.Select)
 	sql, paramArgs, err := builder.buildSelectSql(
@@ -382,20 +628,29 @@ func (mc *MysqlType) SelectById(
 	if err != nil {
 		return true, err
 	}
-	return mc.rawSelectFirst(dest, sql, paramArgs...)
+	return mc.rawSelectFirst(ctx, dest, sql, paramArgs...)
 }
 
 func (mc *MysqlType) Select(
 	dest interface{},
 	selectParams *t_mysql.SelectParams,
 	values ...interface{},
+) error {
+	return mc.SelectContext(context.Background(), dest, selectParams, values...)
+}
+
+func (mc *MysqlType) SelectContext(
+	ctx context.Context,
+	dest interface{},
+	selectParams *t_mysql.SelectParams,
+	values ...interface{},
 ) error {
 	selectParams.Select = mc.replaceIfStar(dest, selectParams.Select)
 	sql, paramArgs, err := builder.buildSelectSql(selectParams, values...)
 	if err != nil {
 		return err
 	}
-	err = mc.rawSelect(dest, sql, paramArgs...)
+	err = mc.rawSelect(ctx, dest, sql, paramArgs...)
 	if err != nil {
 		return err
 	}
@@ -405,50 +660,79 @@ func (mc *MysqlType) Select(
 func (mc *MysqlType) Insert(tableName string, params interface{}) (
 	lastInsertId uint64,
 	err error,
+) {
+	return mc.InsertContext(context.Background(), tableName, params)
+}
+
+func (mc *MysqlType) InsertContext(ctx context.Context, tableName string, params interface{}) (
+	lastInsertId uint64,
+	err error,
 ) {
 	sql, paramArgs, err := builder.buildInsertSql(tableName, params)
 	if err != nil {
 		return 0, err
 	}
-	return mc.RawExec(sql, paramArgs...)
+	return mc.RawExecContext(ctx, sql, paramArgs...)
 }
 
 func (mc *MysqlType) InsertIgnore(tableName string, params interface{}) (
 	lastInsertId uint64,
 	err error,
+) {
+	return mc.InsertIgnoreContext(context.Background(), tableName, params)
+}
+
+func (mc *MysqlType) InsertIgnoreContext(ctx context.Context, tableName string, params interface{}) (
+	lastInsertId uint64,
+	err error,
 ) {
 	sql, paramArgs, err := builder.buildInsertSql(tableName, params)
 	if err != nil {
 		return 0, err
 	}
-	return mc.RawExec(sql, paramArgs...)
+	return mc.RawExecContext(ctx, sql, paramArgs...)
 }
 
 func (mc *MysqlType) Update(updateParams *t_mysql.UpdateParams, values ...interface{}) (
 	lastInsertId uint64,
 	err error,
+) {
+	return mc.UpdateContext(context.Background(), updateParams, values...)
+}
+
+func (mc *MysqlType) UpdateContext(ctx context.Context, updateParams *t_mysql.UpdateParams, values ...interface{}) (
+	lastInsertId uint64,
+	err error,
 ) {
 	sql, paramArgs, err := builder.buildUpdateSql(updateParams, values...)
 	if err != nil {
 		return 0, err
 	}
-	return mc.RawExec(sql, paramArgs...)
+	return mc.RawExecContext(ctx, sql, paramArgs...)
 }
 
-func (mc *MysqlType) rawSelectFirst(dest interface{}, sql string, values ...interface{}) (
+func (mc *MysqlType) rawSelectFirst(ctx context.Context, dest interface{}, sql string, values ...interface{}) (
 	notFound bool,
 	err error,
 ) {
+	mc = mc.ambientTx(ctx)
 	sql, values, err = mc.processValues(sql, values)
 	mc.printDebugInfo(sql, values)
 	if err != nil {
 		return true, err
 	}
 
+	leave, err := mc.enterTx()
+	if err != nil {
+		return true, err
+	}
+	defer leave()
+	ctx, stop := mc.abortableContext(ctx)
+	defer stop()
 	if mc.tx != nil {
-		err = mc.tx.Get(dest, sql, values...)
+		err = mc.tx.GetContext(ctx, dest, sql, values...)
 	} else {
-		err = mc.db.Get(dest, sql, values...)
+		err = mc.readerDb(ctx).GetContext(ctx, dest, sql, values...)
 	}
 	if err != nil {
 		if err.Error() == `sql: no rows in result set` {
@@ -462,38 +746,181 @@ func (mc *MysqlType) rawSelectFirst(dest interface{}, sql string, values ...inte
 }
 
 func (mc *MysqlType) Begin() (i_mysql.IMysql, error) {
+	return mc.BeginContext(context.Background())
+}
+
+// BeginContext 开启的事务与 ctx 绑定，提交前 ctx 被取消的话驱动会自动回滚
+func (mc *MysqlType) BeginContext(ctx context.Context) (i_mysql.IMysql, error) {
+	return mc.beginTx(ctx)
+}
+
+func (mc *MysqlType) beginTx(ctx context.Context) (*MysqlType, error) {
+	return mc.beginTxWithOptions(ctx, nil)
+}
+
+func (mc *MysqlType) beginTxWithOptions(ctx context.Context, opts *TxOptions) (*MysqlType, error) {
+	mc = mc.ambientTx(ctx)
+	if mc.tx != nil {
+		if opts != nil {
+			return nil, errors.New(`Transaction options cannot be applied to a nested transaction.`)
+		}
+		return mc.beginSavepoint(ctx)
+	}
 	id := uuid.New().String()
-	mc.printDebugInfo(`begin`, nil)
-	tx, err := mc.db.Beginx()
+	mc.printDebugInfo(`begin`, opts)
+	tx, err := opts.begin(ctx, mc.db)
+	if err != nil {
+		return nil, err
+	}
+	abortCtx, abort := context.WithCancel(context.Background())
+	txInstance := &MysqlType{
+		db:           nil,
+		txId:         id,
+		tx:           tx,
+		savepointSeq: new(uint64),
+		hooks:        &txHooks{},
+		busy:         new(int32),
+		beganAt:      time.Now(),
+		beginStack:   debug.Stack(),
+		abortCtx:     abortCtx,
+		abort:        abort,
+		txTracker:    mc.txTracker,
+		tagName:      mc.tagName,
+		logger:       mc.logger,
+	}
+	mc.txTracker.add(txInstance)
+	if consistencySessionFromContext(ctx) != nil {
+		hookCtx := context.WithoutCancel(ctx)
+		txInstance.OnCommit(func() {
+			mc.captureGtid(hookCtx, mc.db)
+		})
+	}
+	return txInstance, nil
+}
+
+// beginSavepoint 在已有事务中开启嵌套事务，Commit/Rollback 分别对应 release/rollback to savepoint
+func (mc *MysqlType) beginSavepoint(ctx context.Context) (*MysqlType, error) {
+	leave, err := mc.enterTx()
 	if err != nil {
 		return nil, err
 	}
+	defer leave()
+	ctx, stop := mc.abortableContext(ctx)
+	defer stop()
+
+	savepoint := fmt.Sprintf(`sp_%d`, atomic.AddUint64(mc.savepointSeq, 1))
+	sql := fmt.Sprintf(`savepoint %s`, savepoint)
+	mc.printDebugInfo(sql, nil)
+	_, err = mc.tx.ExecContext(ctx, sql)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 	return &MysqlType{
-		db:      nil,
-		txId:    id,
-		tx:      tx,
-		tagName: mc.tagName,
-		logger:  mc.logger,
+		db:           nil,
+		txId:         mc.txId,
+		tx:           mc.tx,
+		savepoint:    savepoint,
+		savepointSeq: mc.savepointSeq,
+		parent:       mc,
+		hooks:        &txHooks{},
+		busy:         mc.busy,
+		abortCtx:     mc.abortCtx,
+		abort:        mc.abort,
+		txTracker:    mc.txTracker,
+		tagName:      mc.tagName,
+		logger:       mc.logger,
 	}, nil
 }
 
 func (mc *MysqlType) Commit() error {
+	if mc.tx == nil {
+		return ErrNotInTransaction
+	}
+	if mc.xid != "" {
+		return ErrXaBranch
+	}
+	leave, err := mc.enterTx()
+	if err != nil {
+		return err
+	}
+
+	if mc.savepoint != "" {
+		err := mc.execSavepoint(fmt.Sprintf(`release savepoint %s`, mc.savepoint))
+		leave()
+		if err != nil {
+			return err
+		}
+		atomic.StoreInt32(&mc.state, txStateCommitted)
+		// 嵌套事务的改动要等外层事务结束才算数
+		mc.hooks.moveTo(mc.parent.hooks)
+		return nil
+	}
 	mc.printDebugInfo(`commit`, nil)
 
-	err := mc.tx.Commit()
+	err = mc.tx.Commit()
+	leave()
+	if errors.Is(err, sql2.ErrTxDone) {
+		// 看门狗已经强制回滚了，由它执行回调
+		return ErrTxDone
+	}
 	if err != nil {
+		// 提交失败后事务也已经结束了，mysql 会回滚
+		mc.finishTx(txStateRolledBack)
 		return err
 	}
+	if !mc.finishTx(txStateCommitted) {
+		return ErrTxDone
+	}
+	mc.hooks.fireCommit(mc)
 	return nil
 }
 
 func (mc *MysqlType) Rollback() error {
+	if mc.tx == nil {
+		return ErrNotInTransaction
+	}
+	if mc.xid != "" {
+		return ErrXaBranch
+	}
+	leave, err := mc.enterTx()
+	if err != nil {
+		return err
+	}
+
+	if mc.savepoint != "" {
+		err := mc.execSavepoint(fmt.Sprintf(`rollback to savepoint %s`, mc.savepoint))
+		leave()
+		if err != nil {
+			return err
+		}
+		atomic.StoreInt32(&mc.state, txStateRolledBack)
+		mc.hooks.fireRollback(mc)
+		return nil
+	}
 	mc.printDebugInfo(`rollback`, nil)
 
-	err := mc.tx.Rollback()
+	err = mc.tx.Rollback()
+	leave()
+	if errors.Is(err, sql2.ErrTxDone) {
+		// 看门狗已经强制回滚了，由它执行回调
+		return ErrTxDone
+	}
+	if !mc.finishTx(txStateRolledBack) {
+		return ErrTxDone
+	}
 	if err != nil {
 		return err
 	}
+	mc.hooks.fireRollback(mc)
+	return nil
+}
+
+func (mc *MysqlType) execSavepoint(sql string) error {
+	mc.printDebugInfo(sql, nil)
+	_, err := mc.tx.Exec(sql)
+	if err != nil {
+		return errors.WithStack(err)
+	}
 	return nil
 }
 
@@ -589,6 +1016,10 @@ func (mysql *builderClass) buildWhereFromMap(ele map[string]interface{}) (
 	cols, ops, vals, args := mysql.buildFromMap(ele)
 
 	for i, col := range cols {
+		if vals[i] == "" {
+			andStr = andStr + fmt.Sprintf("`%s` %s and ", col, ops[i])
+			continue
+		}
 		andStr = andStr + fmt.Sprintf("`%s` %s %s and ", col, ops[i], vals[i])
 	}
 	if len(andStr) > 4 {
@@ -612,6 +1043,18 @@ func (mysql *builderClass) buildFromMap(ele map[string]interface{}) (
 			continue
 		}
 
+		if condition, ok := val.(Condition); ok {
+			op, val_, args_, skip := condition.buildCondition()
+			if skip {
+				continue
+			}
+			cols = append(cols, key)
+			ops = append(ops, op)
+			vals = append(vals, val_)
+			args = append(args, args_...)
+			continue
+		}
+
 		kind := reflect.TypeOf(val).Kind()
 		if kind == reflect.Slice {
 			value_ := reflect.ValueOf(val)
@@ -639,6 +1082,7 @@ func (mysql *builderClass) buildFromMap(ele map[string]interface{}) (
 		} else {
 			cols = append(cols, key)
 			str := go_format.ToString(val)
+			// 已废弃，"s:" 之后的 sql 不绑定参数，有注入风险，使用 Condition 代替
 			if strings.HasPrefix(str, `s:`) {
 				r := strings.Trim(str[2:], " ")
 				index := strings.Index(r, " ")
@@ -662,6 +1106,16 @@ func (mysql *builderClass) buildWhere(where interface{}, args []interface{}) (
 	if where == nil {
 		return make([]interface{}, 0), "", nil
 	}
+	if node, ok := where.(*WhereNode); ok {
+		str, paramArgs, _, err := node.build(mysql)
+		if err != nil {
+			return nil, ``, err
+		}
+		if str == "" {
+			return paramArgs, "", nil
+		}
+		return paramArgs, "where " + str, nil
+	}
 	type_ := reflect.TypeOf(where)
 	paramArgs = args
 	str := ``
@@ -710,27 +1164,68 @@ func (mysql *builderClass) buildWhere(where interface{}, args []interface{}) (
 	return paramArgs, "", nil
 }
 
+// selectQuery SelectParams 以及 Query 都编译成 selectQuery 再生成 sql
+type selectQuery struct {
+	tableName string
+	select_   string
+	where     interface{}
+	orderBy   []OrderBy
+	limit     uint64
+	offset    uint64
+}
+
 func (mysql *builderClass) buildSelectSql(selectParams *t_mysql.SelectParams, values ...interface{}) (
 	sql string,
 	paramArgs []interface{},
 	err error,
 ) {
-	paramArgs, whereStr, err := mysql.buildWhere(selectParams.Where, values)
+	query := &selectQuery{
+		tableName: selectParams.TableName,
+		select_:   selectParams.Select,
+		where:     selectParams.Where,
+		limit:     selectParams.Limit,
+	}
+	if selectParams.OrderBy != nil {
+		query.orderBy = []OrderBy{{
+			Col:   selectParams.OrderBy.Col,
+			Order: selectParams.OrderBy.Order,
+		}}
+	}
+	return mysql.buildSelectQuery(query, values...)
+}
+
+func (mysql *builderClass) buildSelectQuery(query *selectQuery, values ...interface{}) (
+	sql string,
+	paramArgs []interface{},
+	err error,
+) {
+	paramArgs, whereStr, err := mysql.buildWhere(query.where, values)
 	if err != nil {
 		return ``, nil, err
 	}
 
 	str := fmt.Sprintf(
 		"select %s from `%s` %s",
-		selectParams.Select,
-		selectParams.TableName,
+		query.select_,
+		query.tableName,
 		whereStr,
 	)
-	if selectParams.OrderBy != nil {
-		str += fmt.Sprintf(" order by `%s` %s", selectParams.OrderBy.Col, selectParams.OrderBy.Order)
+	if len(query.orderBy) > 0 {
+		orderByStr, orderByArgs, err := mysql.buildOrderBy(query.orderBy)
+		if err != nil {
+			return ``, nil, err
+		}
+		str += " " + orderByStr
+		paramArgs = append(paramArgs, orderByArgs...)
+	}
+	if query.limit != 0 {
+		str += fmt.Sprintf(" limit %d", query.limit)
+	} else if query.offset != 0 {
+		// mysql 的 offset 必须和 limit 一起使用
+		str += fmt.Sprintf(" limit %d", uint64(math.MaxUint64))
 	}
-	if selectParams.Limit != 0 {
-		str += fmt.Sprintf(" limit %d", selectParams.Limit)
+	if query.offset != 0 {
+		str += fmt.Sprintf(" offset %d", query.offset)
 	}
 	return str, paramArgs, nil
 }
@@ -1,13 +1,20 @@
 package go_mysql
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	i_logger "github.com/pefish/go-interface/i-logger"
 	t_mysql "github.com/pefish/go-interface/t-mysql"
+	"github.com/pefish/go-mysql/sqlx"
 	go_test_ "github.com/pefish/go-test"
 )
 
@@ -376,3 +383,166 @@ func Test_builderClass_buildInsertSql(t *testing.T) {
 	go_test_.Equal(t, true, strings.HasPrefix(strings.ToLower(sql), "insert into `table`"))
 	go_test_.Equal(t, 2, len(args))
 }
+
+type fakeRecorder struct {
+	mu         sync.Mutex
+	stmts      []string
+	execErr    func(query string) error
+	rows       func(query string) ([]string, [][]driver.Value)
+	connectErr error
+	// 返回 true 的语句一直阻塞到 ctx 取消，模拟等锁
+	block func(query string) bool
+}
+
+func (r *fakeRecorder) record(query string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stmts = append(r.stmts, query)
+	if r.execErr != nil {
+		return r.execErr(query)
+	}
+	return nil
+}
+
+func (r *fakeRecorder) Stmts() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.stmts...)
+}
+
+type fakeConnector struct {
+	recorder *fakeRecorder
+}
+
+func (c *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.recorder.connectErr != nil {
+		return nil, c.recorder.connectErr
+	}
+	return &fakeConn{recorder: c.recorder}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	recorder *fakeRecorder
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	query := "begin"
+	if opts.Isolation != 0 {
+		query += " " + sql.IsolationLevel(opts.Isolation).String()
+	}
+	if opts.ReadOnly {
+		query += " read only"
+	}
+	if err := c.recorder.record(query); err != nil {
+		return nil, err
+	}
+	return &fakeTx{recorder: c.recorder}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.recorder.record(query); err != nil {
+		return nil, err
+	}
+	if c.recorder.block != nil && c.recorder.block(query) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return fakeResult{}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.recorder.record(query); err != nil {
+		return nil, err
+	}
+	rows := &fakeRows{}
+	if c.recorder.rows != nil {
+		rows.columns, rows.values = c.recorder.rows(query)
+	}
+	return rows, nil
+}
+
+type fakeResult struct{}
+
+func (r fakeResult) LastInsertId() (int64, error) {
+	return 1, nil
+}
+
+func (r fakeResult) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+type fakeTx struct {
+	recorder *fakeRecorder
+}
+
+func (t *fakeTx) Commit() error {
+	return t.recorder.record("commit")
+}
+
+func (t *fakeTx) Rollback() error {
+	return t.recorder.record("rollback")
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func newFakeMysql() (*MysqlType, *fakeRecorder) {
+	recorder := &fakeRecorder{}
+	mysql := NewMysqlInstance(&i_logger.DefaultLogger)
+	mysql.db = sqlx.NewDb(sql.OpenDB(&fakeConnector{recorder: recorder}), "mysql")
+	mysql.db.SetTagName(mysql.tagName)
+	return mysql, recorder
+}
+
+func TestMysqlType_Context(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := mysql.RawExecContext(ctx, "update `table` set `a` = ?", 1)
+	go_test_.Equal(t, true, errors.Is(err, context.Canceled))
+	_, err = mysql.CountContext(ctx, &t_mysql.CountParams{TableName: "table"})
+	go_test_.Equal(t, true, errors.Is(err, context.Canceled))
+	_, err = mysql.BeginContext(ctx)
+	go_test_.Equal(t, true, errors.Is(err, context.Canceled))
+	go_test_.Equal(t, 0, len(recorder.Stmts()))
+
+	_, err = mysql.RawExecContext(context.Background(), "update `table` set `a` = ?", 1)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, []string{"update `table` set `a` = ?"}, recorder.Stmts())
+}
@@ -0,0 +1,239 @@
+package go_mysql
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	"github.com/pkg/errors"
+)
+
+var DEFAULT_POOL_SETTINGS_WATCH_INTERVAL = 5 * time.Second
+
+// PoolSettings 连接池配置，含义同 sql.DB 对应的 Set 方法，0 表示不限制
+type PoolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func poolSettingsFrom(configuration t_mysql.Configuration, connMaxIdleTime time.Duration) PoolSettings {
+	settings := PoolSettings{
+		MaxOpenConns:    DEFAULT_MAX_OPEN_CONNS,
+		MaxIdleConns:    DEFAULT_MAX_IDLE_CONNS,
+		ConnMaxLifetime: DEFAULT_CONN_MAX_LIFTTIME,
+		ConnMaxIdleTime: connMaxIdleTime,
+	}
+	if configuration.MaxOpenConns != 0 {
+		settings.MaxOpenConns = configuration.MaxOpenConns
+	}
+	if configuration.MaxIdleConns != 0 {
+		settings.MaxIdleConns = configuration.MaxIdleConns
+	}
+	if configuration.ConnMaxLifetime != 0 {
+		settings.ConnMaxLifetime = configuration.ConnMaxLifetime
+	}
+	// 和 sql.DB 一样，闲置连接数不超过最大连接数，比如只设置了较小的 MaxOpenConns 时
+	if settings.MaxOpenConns > 0 && settings.MaxIdleConns > settings.MaxOpenConns {
+		settings.MaxIdleConns = settings.MaxOpenConns
+	}
+	return settings
+}
+
+// validate 只校验 Reconfigure 明确传入的配置
+func (s PoolSettings) validate() error {
+	if s.MaxOpenConns < 0 {
+		return fieldError(`max_open_conns`, errors.New(`Must not be negative.`))
+	}
+	if s.MaxIdleConns < 0 {
+		return fieldError(`max_idle_conns`, errors.New(`Must not be negative.`))
+	}
+	if s.MaxOpenConns > 0 && s.MaxIdleConns > s.MaxOpenConns {
+		return fieldError(`max_idle_conns`, errors.New(`Must not be greater than max_open_conns.`))
+	}
+	if s.ConnMaxLifetime < 0 {
+		return fieldError(`conn_max_lifetime`, errors.New(`Must not be negative.`))
+	}
+	if s.ConnMaxIdleTime < 0 {
+		return fieldError(`conn_max_idle_time`, errors.New(`Must not be negative.`))
+	}
+	return nil
+}
+
+func (s PoolSettings) apply(db interface {
+	SetMaxOpenConns(n int)
+	SetMaxIdleConns(n int)
+	SetConnMaxLifetime(d time.Duration)
+	SetConnMaxIdleTime(d time.Duration)
+}) {
+	db.SetMaxOpenConns(s.MaxOpenConns)       // 用于设置最大打开的连接数，默认值为0表示不限制
+	db.SetMaxIdleConns(s.MaxIdleConns)       // 用于设置闲置的连接数
+	db.SetConnMaxLifetime(s.ConnMaxLifetime) // 设置一个超时时间，时间小于数据库的超时时间即可
+	db.SetConnMaxIdleTime(s.ConnMaxIdleTime)
+}
+
+// PoolSettings 当前的连接池配置
+func (mc *MysqlType) PoolSettings() PoolSettings {
+	mc.poolLock.Lock()
+	defer mc.poolLock.Unlock()
+	return mc.poolSettings
+}
+
+// Reconfigure 修改运行中的主库的连接池配置，从库使用 ReconfigureReplica。调小 MaxOpenConns 时正在使用的连接归还后才会关闭
+func (mc *MysqlType) Reconfigure(settings PoolSettings) error {
+	if mc.tx != nil {
+		return errors.New(`Pool must be reconfigured on a non-transaction instance.`)
+	}
+	if mc.db == nil {
+		return errors.New(`Not connected.`)
+	}
+	err := settings.validate()
+	if err != nil {
+		return err
+	}
+	mc.poolLock.Lock()
+	defer mc.poolLock.Unlock()
+	settings.apply(mc.db.DB)
+	if settings != mc.poolSettings {
+		mc.logger.Info(fmt.Sprintf(`mysql pool reconfigured. from: %+v, to: %+v`, mc.poolSettings, settings))
+	}
+	mc.poolSettings = settings
+	return nil
+}
+
+// PoolSettings 从库当前的连接池配置
+func (r *Replica) PoolSettings() PoolSettings {
+	r.poolLock.Lock()
+	defer r.poolLock.Unlock()
+	return r.poolSettings
+}
+
+// ReconfigureReplica 修改地址为 address 的从库的连接池配置，其他从库以及主库不受影响
+func (mc *MysqlType) ReconfigureReplica(address string, settings PoolSettings) error {
+	if mc.tx != nil {
+		return errors.New(`Pool must be reconfigured on a non-transaction instance.`)
+	}
+	err := settings.validate()
+	if err != nil {
+		return err
+	}
+	for _, replica := range mc.replicas {
+		if replica.address != address {
+			continue
+		}
+		replica.poolLock.Lock()
+		settings.apply(replica.db.DB)
+		if settings != replica.poolSettings {
+			mc.logger.Info(fmt.Sprintf(`mysql replica pool reconfigured. url: %s, from: %+v, to: %+v`, address, replica.poolSettings, settings))
+		}
+		replica.poolSettings = settings
+		replica.poolLock.Unlock()
+		return nil
+	}
+	return errors.Errorf(`Replica <%s> not found.`, address)
+}
+
+// loadPoolSettings 从 json 或者 yaml 文件读取连接池配置，字段名同 ConfigurationFromFile，文件里没有的字段保持 current 的值。
+// 文件里没有 max_idle_conns 时闲置连接数不超过新的 max_open_conns
+func loadPoolSettings(path string, current PoolSettings) (PoolSettings, error) {
+	values, err := readValuesFile(path)
+	if err != nil {
+		return PoolSettings{}, err
+	}
+	result := current
+	for field, value := range values {
+		switch field {
+		case `max_open_conns`:
+			result.MaxOpenConns, err = parseNonNegativeInt(field, stringValue(value))
+		case `max_idle_conns`:
+			result.MaxIdleConns, err = parseNonNegativeInt(field, stringValue(value))
+		case `conn_max_lifetime`:
+			result.ConnMaxLifetime, err = time.ParseDuration(stringValue(value))
+			if err != nil {
+				err = fieldError(field, err)
+			}
+		case `conn_max_idle_time`:
+			result.ConnMaxIdleTime, err = time.ParseDuration(stringValue(value))
+			if err != nil {
+				err = fieldError(field, err)
+			}
+		default:
+			err = fieldError(field, errors.New(`Unknown field.`))
+		}
+		if err != nil {
+			return PoolSettings{}, err
+		}
+	}
+	if _, ok := values[`max_idle_conns`]; !ok && result.MaxOpenConns > 0 && result.MaxIdleConns > result.MaxOpenConns {
+		result.MaxIdleConns = result.MaxOpenConns
+	}
+	return result, nil
+}
+
+// StartPoolSettingsWatcher 立即应用一次 path 里的主库连接池配置，之后每隔 interval 检查文件，内容变化则重新应用。
+// 文件读取或者配置有误时只记录错误，保持当前配置。重复调用会替换之前的文件，Close 时自动停止
+func (mc *MysqlType) StartPoolSettingsWatcher(path string, interval time.Duration) error {
+	if interval == 0 {
+		interval = DEFAULT_POOL_SETTINGS_WATCH_INTERVAL
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = mc.reloadPoolSettings(path)
+	if err != nil {
+		return err
+	}
+
+	mc.StopPoolSettingsWatcher()
+	stop := make(chan struct{})
+	mc.poolLock.Lock()
+	mc.poolWatcherStop = stop
+	mc.poolLock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				newContent, err := os.ReadFile(path)
+				if err != nil {
+					mc.logger.Error(errors.WithStack(err))
+					continue
+				}
+				if bytes.Equal(newContent, content) {
+					continue
+				}
+				content = newContent
+				err = mc.reloadPoolSettings(path)
+				if err != nil {
+					mc.logger.Error(err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (mc *MysqlType) StopPoolSettingsWatcher() {
+	mc.poolLock.Lock()
+	defer mc.poolLock.Unlock()
+	if mc.poolWatcherStop != nil {
+		close(mc.poolWatcherStop)
+		mc.poolWatcherStop = nil
+	}
+}
+
+func (mc *MysqlType) reloadPoolSettings(path string) error {
+	settings, err := loadPoolSettings(path, mc.PoolSettings())
+	if err != nil {
+		return err
+	}
+	return mc.Reconfigure(settings)
+}
@@ -0,0 +1,125 @@
+package go_mysql
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	i_logger "github.com/pefish/go-interface/i-logger"
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestMysqlType_Reconfigure(t *testing.T) {
+	mysql, _ := newFakeMysql()
+	replica, _ := newFakeReplica("replica", 1)
+	mysql.replicas = []*Replica{replica}
+
+	err := mysql.Reconfigure(PoolSettings{MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: time.Minute})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, 10, mysql.db.Stats().MaxOpenConnections)
+	go_test_.Equal(t, PoolSettings{MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: time.Minute}, mysql.PoolSettings())
+	// 从库单独配置，不受主库影响
+	go_test_.Equal(t, 0, replica.db.Stats().MaxOpenConnections)
+	err = mysql.ReconfigureReplica("replica", PoolSettings{MaxOpenConns: 3, MaxIdleConns: 1})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, 3, replica.db.Stats().MaxOpenConnections)
+	go_test_.Equal(t, PoolSettings{MaxOpenConns: 3, MaxIdleConns: 1}, replica.PoolSettings())
+	go_test_.Equal(t, 10, mysql.db.Stats().MaxOpenConnections)
+	go_test_.Equal(t, "Replica <missing> not found.", mysql.ReconfigureReplica("missing", PoolSettings{}).Error())
+
+	err = mysql.Reconfigure(PoolSettings{MaxOpenConns: 2, MaxIdleConns: 5})
+	go_test_.Equal(t, "max_idle_conns", configurationErrorField(err))
+	go_test_.Equal(t, 10, mysql.db.Stats().MaxOpenConnections)
+
+	tx, err := mysql.Begin()
+	go_test_.Equal(t, nil, err)
+	go_test_.NotEqual(t, nil, tx.(*MysqlType).Reconfigure(PoolSettings{}))
+	go_test_.Equal(t, nil, tx.Rollback())
+}
+
+func TestMysqlType_PoolSettingsWatcher(t *testing.T) {
+	mysql, _ := newFakeMysql()
+	err := mysql.Reconfigure(PoolSettings{MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: time.Minute})
+	go_test_.Equal(t, nil, err)
+
+	path := filepath.Join(t.TempDir(), "pool.yaml")
+	go_test_.Equal(t, nil, os.WriteFile(path, []byte("max_open_conns: 20\n"), 0600))
+	err = mysql.StartPoolSettingsWatcher(path, 5*time.Millisecond)
+	go_test_.Equal(t, nil, err)
+	defer mysql.StopPoolSettingsWatcher()
+	// 文件里没有的字段保持原值
+	go_test_.Equal(t, PoolSettings{MaxOpenConns: 20, MaxIdleConns: 5, ConnMaxLifetime: time.Minute}, mysql.PoolSettings())
+
+	// 配置有误时保持当前配置
+	go_test_.Equal(t, nil, os.WriteFile(path, []byte("max_open_conns: abc\n"), 0600))
+	time.Sleep(30 * time.Millisecond)
+	go_test_.Equal(t, 20, mysql.PoolSettings().MaxOpenConns)
+
+	go_test_.Equal(t, nil, os.WriteFile(path, []byte("max_open_conns: 3\nmax_idle_conns: 1\nconn_max_idle_time: 30s\n"), 0600))
+	deadline := time.Now().Add(time.Second)
+	for mysql.PoolSettings().MaxOpenConns != 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	go_test_.Equal(t, PoolSettings{MaxOpenConns: 3, MaxIdleConns: 1, ConnMaxLifetime: time.Minute, ConnMaxIdleTime: 30 * time.Second}, mysql.PoolSettings())
+	go_test_.Equal(t, 3, mysql.db.Stats().MaxOpenConnections)
+}
+
+func TestLoadPoolSettings(t *testing.T) {
+	current := PoolSettings{MaxOpenConns: 100, MaxIdleConns: 30, ConnMaxLifetime: time.Minute}
+	path := filepath.Join(t.TempDir(), "pool.yaml")
+
+	// 只调小 max_open_conns 时闲置连接数跟着调小
+	go_test_.Equal(t, nil, os.WriteFile(path, []byte("max_open_conns: 10\n"), 0600))
+	settings, err := loadPoolSettings(path, current)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, PoolSettings{MaxOpenConns: 10, MaxIdleConns: 10, ConnMaxLifetime: time.Minute}, settings)
+	go_test_.Equal(t, nil, settings.validate())
+
+	// 明确设置的 max_idle_conns 不调整，交给 validate 检查
+	go_test_.Equal(t, nil, os.WriteFile(path, []byte("max_open_conns: 10\nmax_idle_conns: 20\n"), 0600))
+	settings, err = loadPoolSettings(path, current)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, 20, settings.MaxIdleConns)
+	go_test_.Equal(t, "max_idle_conns", configurationErrorField(settings.validate()))
+
+	go_test_.Equal(t, nil, os.WriteFile(path, []byte("max_open_conns: abc\n"), 0600))
+	_, err = loadPoolSettings(path, current)
+	go_test_.Equal(t, "max_open_conns", configurationErrorField(err))
+	go_test_.Equal(t, 1, strings.Count(err.Error(), "Configuration field"))
+
+	go_test_.Equal(t, nil, os.WriteFile(path, []byte("conn_max_lifetime: abc\n"), 0600))
+	_, err = loadPoolSettings(path, current)
+	go_test_.Equal(t, "conn_max_lifetime", configurationErrorField(err))
+	go_test_.Equal(t, 1, strings.Count(err.Error(), "Configuration field"))
+}
+
+func TestMysqlType_ConnectSmallMaxOpenConns(t *testing.T) {
+	mysql := NewMysqlInstance(&i_logger.DefaultLogger)
+	err := mysql.Connect(context.Background(), &Configuration{
+		Configuration: t_mysql.Configuration{
+			Host:         "db",
+			Username:     "root",
+			MaxOpenConns: 10,
+		},
+		Replicas: []ReplicaConfiguration{
+			{Configuration: t_mysql.Configuration{Host: "replica", MaxOpenConns: 5}},
+		},
+		LazyConnect: true,
+	})
+	go_test_.Equal(t, nil, err)
+	defer mysql.Close()
+	// 默认的闲置连接数不超过 MaxOpenConns
+	go_test_.Equal(t, 10, mysql.PoolSettings().MaxIdleConns)
+	go_test_.Equal(t, 10, mysql.db.Stats().MaxOpenConnections)
+	go_test_.Equal(t, 5, mysql.replicas[0].db.Stats().MaxOpenConnections)
+	go_test_.Equal(t, 5, mysql.replicas[0].PoolSettings().MaxIdleConns)
+
+	// 主库的配置不会覆盖从库的配置
+	err = mysql.Reconfigure(PoolSettings{MaxOpenConns: 20, MaxIdleConns: 20})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, 5, mysql.replicas[0].db.Stats().MaxOpenConnections)
+}
@@ -0,0 +1,134 @@
+package go_mysql
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// Query 链式查询，比如 mc.Table("orders").Select("id", "amount").Where(...).OrderBy("created_at desc").Limit(20).Find(&dest)
+type Query struct {
+	mc        *MysqlType
+	tableName string
+	columns   []string
+	wheres    []interface{}
+	orderBy   []OrderBy
+	limit     uint64
+	offset    uint64
+}
+
+func (mc *MysqlType) Table(tableName string) *Query {
+	return &Query{
+		mc:        mc,
+		tableName: tableName,
+	}
+}
+
+// Select 列名会加上反引号，其他表达式（比如 count(*) as count）原样使用。不调用则根据 dest 的 tag 生成
+func (q *Query) Select(columns ...string) *Query {
+	q.columns = append(q.columns, columns...)
+	return q
+}
+
+// Where 支持 SelectParams.Where 的所有类型。字符串条件用 ? 占位，值放在 values 里。多次调用之间是 and
+func (q *Query) Where(where interface{}, values ...interface{}) *Query {
+	if where == nil {
+		return q
+	}
+	if str, ok := where.(string); ok {
+		q.wheres = append(q.wheres, rawWhere{sql: str, args: values})
+		return q
+	}
+	q.wheres = append(q.wheres, where)
+	return q
+}
+
+// OrderBy 排序表达式原样使用，比如 "created_at desc"。多次调用按顺序排序
+func (q *Query) OrderBy(orderBy string) *Query {
+	q.orderBy = append(q.orderBy, OrderBy{Expr: orderBy})
+	return q
+}
+
+// Order 和 OrderBy 一样，排序方向会校验
+func (q *Query) Order(orders ...OrderBy) *Query {
+	q.orderBy = append(q.orderBy, orders...)
+	return q
+}
+
+func (q *Query) Limit(limit uint64) *Query {
+	q.limit = limit
+	return q
+}
+
+func (q *Query) Offset(offset uint64) *Query {
+	q.offset = offset
+	return q
+}
+
+func (q *Query) selectColumns(dest interface{}) string {
+	if len(q.columns) == 0 {
+		return q.mc.replaceIfStar(dest, "*")
+	}
+	columns := make([]string, 0, len(q.columns))
+	for _, column := range q.columns {
+		if identifierRegexp.MatchString(column) {
+			column = "`" + column + "`"
+		}
+		columns = append(columns, column)
+	}
+	return strings.Join(columns, ",")
+}
+
+func (q *Query) build(dest interface{}, limit uint64) (sql string, args []interface{}, err error) {
+	query := &selectQuery{
+		tableName: q.tableName,
+		select_:   q.selectColumns(dest),
+		orderBy:   q.orderBy,
+		limit:     limit,
+		offset:    q.offset,
+	}
+	var values []interface{}
+	switch len(q.wheres) {
+	case 0:
+	case 1:
+		query.where = q.wheres[0]
+		if raw, ok := query.where.(rawWhere); ok {
+			// 和 SelectParams 的字符串条件生成一样的 sql
+			query.where, values = raw.sql, raw.args
+		}
+	default:
+		query.where = And(q.wheres...)
+	}
+	return builder.buildSelectQuery(query, values...)
+}
+
+func (q *Query) Find(dest interface{}) error {
+	return q.FindContext(context.Background(), dest)
+}
+
+func (q *Query) FindContext(ctx context.Context, dest interface{}) error {
+	sql, args, err := q.build(dest, q.limit)
+	if err != nil {
+		return err
+	}
+	return q.mc.rawSelect(ctx, dest, sql, args...)
+}
+
+// First 没有设置 Limit 时只查询一条
+func (q *Query) First(dest interface{}) (notFound bool, err error) {
+	return q.FirstContext(context.Background(), dest)
+}
+
+func (q *Query) FirstContext(ctx context.Context, dest interface{}) (notFound bool, err error) {
+	limit := q.limit
+	if limit == 0 {
+		limit = 1
+	}
+	sql, args, err := q.build(dest, limit)
+	if err != nil {
+		return true, err
+	}
+	return q.mc.rawSelectFirst(ctx, dest, sql, args...)
+}
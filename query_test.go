@@ -0,0 +1,83 @@
+package go_mysql
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestQuery_build(t *testing.T) {
+	mysql, _ := newFakeMysql()
+
+	// 和 buildSelectSql 生成一样的 sql
+	expectSql, expectArgs, err := builder.buildSelectSql(&t_mysql.SelectParams{
+		TableName: "orders",
+		Select:    "`id`,`amount`",
+		Where:     map[string]interface{}{"status": 1},
+		OrderBy:   &t_mysql.OrderByType{Col: "id", Order: t_mysql.OrderType_DESC},
+		Limit:     20,
+	})
+	go_test_.Equal(t, nil, err)
+	sql, args, err := mysql.Table("orders").
+		Select("id", "amount").
+		Where(map[string]interface{}{"status": 1}).
+		OrderBy("`id` desc").
+		Limit(20).
+		build(&struct{}{}, 20)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, expectSql, sql)
+	go_test_.Equal(t, expectArgs, args)
+
+	sql, args, err = mysql.Table("orders").
+		Select("id", "count(*) as count").
+		Where("`created_at` > ?", "2024-01-01").
+		build(&struct{}{}, 0)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "select `id`,count(*) as count from `orders` where `created_at` > ?", sql)
+	go_test_.Equal(t, []interface{}{"2024-01-01"}, args)
+
+	query := mysql.Table("orders").
+		Where(map[string]interface{}{"status": 1}).
+		Where("`amount` > ? or `vip` = ?", 100, 1).
+		Where(Or(
+			map[string]interface{}{"a": 1},
+			map[string]interface{}{"b": 2},
+		)).
+		OrderBy("created_at desc").
+		OrderBy("id").
+		Offset(40)
+	sql, args, err = query.build(&struct{}{}, 0)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(
+		t,
+		"select * from `orders` where `status` = ? and (`amount` > ? or `vip` = ?) and (`a` = ? or `b` = ?) order by created_at desc, id limit 18446744073709551615 offset 40",
+		sql,
+	)
+	go_test_.Equal(t, []interface{}{"1", 100, 1, "1", "2"}, args)
+}
+
+func TestQuery_Find(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+	recorder.rows = func(query string) ([]string, [][]driver.Value) {
+		return []string{"id", "amount"}, [][]driver.Value{{int64(1), "10"}, {int64(2), "20"}}
+	}
+	type order struct {
+		Id     uint64 `json:"id"`
+		Amount string `json:"amount"`
+	}
+
+	var orders []order
+	err := mysql.Table("orders").Where(map[string]interface{}{"amount": Gt(5)}).Limit(20).Offset(40).Find(&orders)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, 2, len(orders))
+	go_test_.Equal(t, "select `id`,`amount` from `orders` where `amount` > ? limit 20 offset 40", recorder.Stmts()[0])
+
+	var first order
+	notFound, err := mysql.Table("orders").OrderBy("id desc").First(&first)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, false, notFound)
+	go_test_.Equal(t, uint64(1), first.Id)
+	go_test_.Equal(t, "select `id`,`amount` from `orders`  order by id desc limit 1", recorder.Stmts()[1])
+}
@@ -0,0 +1,93 @@
+package go_mysql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pefish/go-mysql/sqlx"
+	"github.com/pkg/errors"
+)
+
+// ConsistencySession 记录一个会话（比如一个用户）最近一次写入后主库的 gtid_executed，
+// 同一个会话后续的读请求会等从库追上这个 gtid 集合再读，追不上就读主库
+type ConsistencySession struct {
+	lock    sync.Mutex
+	gtidSet string
+}
+
+// NewConsistencySession gtidSet 可以是之前保存下来的 GtidSet()，为空表示还没有写入
+func NewConsistencySession(gtidSet string) *ConsistencySession {
+	return &ConsistencySession{
+		gtidSet: gtidSet,
+	}
+}
+
+func (s *ConsistencySession) GtidSet() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.gtidSet
+}
+
+func (s *ConsistencySession) capture(ctx context.Context, db *sqlx.DB) error {
+	var gtidSet string
+	err := db.QueryRowxContext(ctx, `select @@global.gtid_executed`).Scan(&gtidSet)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.gtidSet = gtidSet
+	return nil
+}
+
+type consistencySessionContextKey struct{}
+
+// ContextWithConsistencySession 使用这个 ctx 在主库上的写入（包括 BeginContext 开启的事务提交）会记录 gtid，
+// 使用这个 ctx 的读请求会保证读到这些写入
+func ContextWithConsistencySession(ctx context.Context, session *ConsistencySession) context.Context {
+	return context.WithValue(ctx, consistencySessionContextKey{}, session)
+}
+
+func consistencySessionFromContext(ctx context.Context) *ConsistencySession {
+	session, _ := ctx.Value(consistencySessionContextKey{}).(*ConsistencySession)
+	return session
+}
+
+// captureGtid 只有配置了从库才需要记录
+func (mc *MysqlType) captureGtid(ctx context.Context, db *sqlx.DB) {
+	session := consistencySessionFromContext(ctx)
+	if session == nil || len(mc.replicas) == 0 {
+		return
+	}
+	err := session.capture(ctx, db)
+	if err != nil {
+		mc.logger.Error(fmt.Sprintf(`capture gtid failed. err: %v`, err))
+	}
+}
+
+// caughtUp 从库是否已经执行了 gtidSet。gtidWaitTimeout 为 0 时不等待
+func (mc *MysqlType) caughtUp(ctx context.Context, replica *Replica, gtidSet string) bool {
+	var result *int64
+	var err error
+	if mc.gtidWaitTimeout > 0 {
+		err = replica.db.QueryRowxContext(
+			ctx,
+			`select wait_for_executed_gtid_set(?, ?)`,
+			gtidSet,
+			mc.gtidWaitTimeout.Seconds(),
+		).Scan(&result)
+	} else {
+		err = replica.db.QueryRowxContext(
+			ctx,
+			`select gtid_subset(?, @@global.gtid_executed) = 0`,
+			gtidSet,
+		).Scan(&result)
+	}
+	if err != nil {
+		mc.logger.Error(fmt.Sprintf(`wait for gtid failed. url: %s, err: %v`, replica.address, err))
+		return false
+	}
+	// 两个语句都是 0 表示已经追上
+	return result != nil && *result == 0
+}
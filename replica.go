@@ -0,0 +1,114 @@
+package go_mysql
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pefish/go-mysql/sqlx"
+)
+
+type Replica struct {
+	db          *sqlx.DB
+	connector   *connector
+	address     string
+	weight      int
+	lag         int64 // time.Duration
+	unavailable int32
+	// 每个从库单独的连接池配置
+	poolLock     sync.Mutex
+	poolSettings PoolSettings
+}
+
+func (r *Replica) Address() string {
+	return r.address
+}
+
+func (r *Replica) Weight() int {
+	return r.weight
+}
+
+func (r *Replica) Stats() sql.DBStats {
+	return r.db.Stats()
+}
+
+// Balancer 从可用的从库中选一个执行读请求，replicas 不会为空
+type Balancer interface {
+	Pick(replicas []*Replica) *Replica
+}
+
+type RoundRobinBalancer struct {
+	next uint64
+}
+
+func (b *RoundRobinBalancer) Pick(replicas []*Replica) *Replica {
+	n := atomic.AddUint64(&b.next, 1)
+	return replicas[(n-1)%uint64(len(replicas))]
+}
+
+// WeightedBalancer 按权重随机选择
+type WeightedBalancer struct {
+}
+
+func (b *WeightedBalancer) Pick(replicas []*Replica) *Replica {
+	total := 0
+	for _, replica := range replicas {
+		total += replica.weight
+	}
+	n := rand.Intn(total)
+	for _, replica := range replicas {
+		if n < replica.weight {
+			return replica
+		}
+		n -= replica.weight
+	}
+	return replicas[len(replicas)-1]
+}
+
+// LeastConnBalancer 选择正在使用的连接数最少的从库
+type LeastConnBalancer struct {
+}
+
+func (b *LeastConnBalancer) Pick(replicas []*Replica) *Replica {
+	var result *Replica
+	minInUse := 0
+	for _, replica := range replicas {
+		inUse := replica.db.Stats().InUse
+		if result == nil || inUse < minInUse {
+			result = replica
+			minInUse = inUse
+		}
+	}
+	return result
+}
+
+type primaryContextKey struct{}
+
+// ContextWithPrimary 使用这个 ctx 的读请求强制走主库
+func ContextWithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// readerDb 读请求使用的连接池，没有可用的从库、强制走主库或者从库没追上会话的写入时使用主库
+func (mc *MysqlType) readerDb(ctx context.Context) *sqlx.DB {
+	if len(mc.replicas) == 0 {
+		return mc.db
+	}
+	if forcePrimary, _ := ctx.Value(primaryContextKey{}).(bool); forcePrimary {
+		return mc.db
+	}
+	replicas := mc.availableReplicas()
+	if len(replicas) == 0 {
+		return mc.db
+	}
+	replica := mc.balancer.Pick(replicas)
+	if session := consistencySessionFromContext(ctx); session != nil {
+		gtidSet := session.GtidSet()
+		if gtidSet != "" && !mc.caughtUp(ctx, replica, gtidSet) {
+			return mc.db
+		}
+	}
+	return replica.db
+}
@@ -0,0 +1,169 @@
+package go_mysql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var DEFAULT_REPLICA_LAG_CHECK_INTERVAL = 5 * time.Second
+
+type ReplicaLagOptions struct {
+	MaxLag   time.Duration // 延迟超过这个值（或者无法获取延迟）的从库不参与负载均衡，必填
+	Interval time.Duration // 检查间隔，默认 DEFAULT_REPLICA_LAG_CHECK_INTERVAL
+	// 心跳表，为空则使用 show replica status。表中 HeartbeatColumn 列由主库定期写入 utc 时间，
+	// 延迟 = 从库当前 utc 时间 - 最新心跳时间，所以结果会包含心跳写入间隔的误差
+	HeartbeatTable  string
+	HeartbeatColumn string // 默认 ts
+}
+
+func (opts *ReplicaLagOptions) validate() error {
+	if opts.MaxLag <= 0 {
+		return errors.New(`MaxLag must be greater than 0.`)
+	}
+	return nil
+}
+
+func (r *Replica) Lag() time.Duration {
+	return time.Duration(atomic.LoadInt64(&r.lag))
+}
+
+// Available 从库是否参与负载均衡
+func (r *Replica) Available() bool {
+	return atomic.LoadInt32(&r.unavailable) == 0
+}
+
+func (r *Replica) measureLag(ctx context.Context, opts *ReplicaLagOptions) (time.Duration, error) {
+	if opts.HeartbeatTable != "" {
+		column := opts.HeartbeatColumn
+		if column == "" {
+			column = `ts`
+		}
+		var lagMicroseconds *int64
+		err := r.db.QueryRowxContext(ctx, fmt.Sprintf(
+			"select timestampdiff(microsecond, max(`%s`), utc_timestamp(6)) from `%s`",
+			column,
+			opts.HeartbeatTable,
+		)).Scan(&lagMicroseconds)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		if lagMicroseconds == nil {
+			return 0, errors.New(`Heartbeat table is empty.`)
+		}
+		return time.Duration(*lagMicroseconds) * time.Microsecond, nil
+	}
+
+	status := make(map[string]interface{})
+	err := r.db.QueryRowxContext(ctx, `show replica status`).MapScan(status)
+	if err != nil {
+		// mysql 8.0.22 之前的版本
+		status = make(map[string]interface{})
+		err = r.db.QueryRowxContext(ctx, `show slave status`).MapScan(status)
+	}
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	seconds, ok := status[`Seconds_Behind_Source`]
+	if !ok {
+		seconds = status[`Seconds_Behind_Master`]
+	}
+	if seconds == nil {
+		return 0, errors.New(`Replication is not running.`)
+	}
+	secondsStr := ``
+	switch v := seconds.(type) {
+	case []byte:
+		secondsStr = string(v)
+	default:
+		secondsStr = fmt.Sprint(v)
+	}
+	secondsInt, err := strconv.ParseInt(secondsStr, 10, 64)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return time.Duration(secondsInt) * time.Second, nil
+}
+
+// StartReplicaLagMonitor 启动后台协程定期检查从库延迟。重复调用会替换之前的配置，Close 时自动停止
+func (mc *MysqlType) StartReplicaLagMonitor(opts ReplicaLagOptions) error {
+	if mc.tx != nil {
+		return errors.New(`Replica lag monitor must be started on a non-transaction instance.`)
+	}
+	err := opts.validate()
+	if err != nil {
+		return err
+	}
+	interval := DEFAULT_REPLICA_LAG_CHECK_INTERVAL
+	if opts.Interval != 0 {
+		interval = opts.Interval
+	}
+
+	mc.StopReplicaLagMonitor()
+	stop := make(chan struct{})
+	mc.lagMonitorLock.Lock()
+	mc.lagMonitorStop = stop
+	mc.lagMonitorLock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			mc.checkReplicaLag(ctx, &opts)
+			cancel()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+func (mc *MysqlType) StopReplicaLagMonitor() {
+	mc.lagMonitorLock.Lock()
+	defer mc.lagMonitorLock.Unlock()
+	if mc.lagMonitorStop != nil {
+		close(mc.lagMonitorStop)
+		mc.lagMonitorStop = nil
+	}
+}
+
+func (mc *MysqlType) checkReplicaLag(ctx context.Context, opts *ReplicaLagOptions) {
+	for _, replica := range mc.replicas {
+		lag, err := replica.measureLag(ctx, opts)
+		available := err == nil && lag <= opts.MaxLag
+		atomic.StoreInt64(&replica.lag, int64(lag))
+		var unavailable int32 = 1
+		if available {
+			unavailable = 0
+		}
+		old := atomic.SwapInt32(&replica.unavailable, unavailable)
+		if old == unavailable {
+			continue
+		}
+		if available {
+			mc.logger.Info(fmt.Sprintf(`mysql replica back in rotation. url: %s, lag: %s`, replica.address, lag))
+		} else if err != nil {
+			mc.logger.Warn(fmt.Sprintf(`mysql replica removed from rotation. url: %s, err: %v`, replica.address, err))
+		} else {
+			mc.logger.Warn(fmt.Sprintf(`mysql replica removed from rotation. url: %s, lag: %s`, replica.address, lag))
+		}
+	}
+}
+
+func (mc *MysqlType) availableReplicas() []*Replica {
+	result := make([]*Replica, 0, len(mc.replicas))
+	for _, replica := range mc.replicas {
+		if replica.Available() {
+			result = append(result, replica)
+		}
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package go_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	i_logger "github.com/pefish/go-interface/i-logger"
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestMysqlType_checkReplicaLag(t *testing.T) {
+	mysql, _ := newFakeMysql()
+	replica1, recorder1 := newFakeReplica("replica1", 1)
+	replica2, recorder2 := newFakeReplica("replica2", 1)
+	replica3, recorder3 := newFakeReplica("replica3", 1)
+	mysql.replicas = []*Replica{replica1, replica2, replica3}
+	mysql.balancer = &RoundRobinBalancer{}
+	replicaStatus := func(secondsBehind driver.Value) func(query string) ([]string, [][]driver.Value) {
+		return func(query string) ([]string, [][]driver.Value) {
+			return []string{"Replica_IO_Running", "Seconds_Behind_Source"}, [][]driver.Value{{[]byte("Yes"), secondsBehind}}
+		}
+	}
+	recorder1.rows = replicaStatus([]byte("1"))
+	recorder2.rows = replicaStatus([]byte("30"))
+	recorder3.rows = replicaStatus(nil)
+
+	mysql.checkReplicaLag(context.Background(), &ReplicaLagOptions{MaxLag: 10 * time.Second})
+	go_test_.Equal(t, time.Second, replica1.Lag())
+	go_test_.Equal(t, true, replica1.Available())
+	go_test_.Equal(t, false, replica2.Available())
+	go_test_.Equal(t, false, replica3.Available())
+	for i := 0; i < 3; i++ {
+		go_test_.Equal(t, replica1.db, mysql.readerDb(context.Background()))
+	}
+
+	recorder1.rows = func(query string) ([]string, [][]driver.Value) {
+		return []string{"lag"}, [][]driver.Value{{int64(20 * time.Second / time.Microsecond)}}
+	}
+	mysql.checkReplicaLag(context.Background(), &ReplicaLagOptions{
+		MaxLag:         10 * time.Second,
+		HeartbeatTable: "heartbeat",
+	})
+	go_test_.Equal(t, "select timestampdiff(microsecond, max(`ts`), utc_timestamp(6)) from `heartbeat`", recorder1.Stmts()[1])
+	go_test_.Equal(t, false, replica1.Available())
+	go_test_.Equal(t, mysql.db, mysql.readerDb(context.Background()))
+}
+
+func TestMysqlType_ReadYourWrites(t *testing.T) {
+	mysql, primaryRecorder := newFakeMysql()
+	replica, replicaRecorder := newFakeReplica("replica", 1)
+	mysql.replicas = []*Replica{replica}
+	mysql.balancer = &RoundRobinBalancer{}
+	mysql.gtidWaitTimeout = time.Second
+	primaryRecorder.rows = func(query string) ([]string, [][]driver.Value) {
+		if query == "select @@global.gtid_executed" {
+			return []string{"gtid"}, [][]driver.Value{{[]byte("uuid:1-10")}}
+		}
+		return []string{"a"}, nil
+	}
+	caughtUp := int64(1)
+	replicaRecorder.rows = func(query string) ([]string, [][]driver.Value) {
+		if strings.HasPrefix(query, "select wait_for_executed_gtid_set") {
+			return []string{"result"}, [][]driver.Value{{caughtUp}}
+		}
+		return []string{"a"}, nil
+	}
+
+	session := NewConsistencySession("")
+	ctx := ContextWithConsistencySession(context.Background(), session)
+	var dest []struct {
+		A string `json:"a"`
+	}
+	selectParams := &t_mysql.SelectParams{TableName: "table", Select: "a"}
+	go_test_.Equal(t, nil, mysql.SelectContext(ctx, &dest, selectParams))
+	go_test_.Equal(t, []string{"select a from `table` "}, replicaRecorder.Stmts())
+
+	_, err := mysql.RawExecContext(ctx, "update `table` set `a` = 1")
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "uuid:1-10", session.GtidSet())
+
+	// 从库没追上，读主库
+	go_test_.Equal(t, nil, mysql.SelectContext(ctx, &dest, selectParams))
+	go_test_.Equal(t, "select a from `table` ", primaryRecorder.Stmts()[2])
+
+	caughtUp = 0
+	go_test_.Equal(t, nil, mysql.SelectContext(ctx, &dest, selectParams))
+	go_test_.Equal(t, "select a from `table` ", replicaRecorder.Stmts()[3])
+
+	session = NewConsistencySession("")
+	err = mysql.WithTransaction(ContextWithConsistencySession(context.Background(), session), func(tx *MysqlType) error {
+		_, err := tx.RawExec("update `table` set `a` = 2")
+		return err
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "uuid:1-10", session.GtidSet())
+}
+
+func TestMysqlType_ConnectInvalidReplicaLag(t *testing.T) {
+	mc := NewMysqlInstance(&i_logger.DefaultLogger)
+	err := mc.Connect(context.Background(), &Configuration{
+		Configuration: t_mysql.Configuration{
+			Host:     "db",
+			Username: "root",
+		},
+		LazyConnect: true,
+		Replicas: []ReplicaConfiguration{
+			{Configuration: t_mysql.Configuration{Host: "replica"}},
+		},
+		ReplicaLag: &ReplicaLagOptions{},
+	})
+	go_test_.Equal(t, "MaxLag must be greater than 0.", err.Error())
+	// 出错时不能留下打开的连接池
+	go_test_.Equal(t, true, mc.db == nil)
+	go_test_.Equal(t, 0, len(mc.replicas))
+}
@@ -0,0 +1,97 @@
+package go_mysql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	"github.com/pefish/go-mysql/sqlx"
+	go_test_ "github.com/pefish/go-test"
+)
+
+func newFakeReplica(address string, weight int) (*Replica, *fakeRecorder) {
+	recorder := &fakeRecorder{}
+	db := sqlx.NewDb(sql.OpenDB(&fakeConnector{recorder: recorder}), "mysql")
+	db.SetTagName(`json`)
+	return &Replica{
+		db:      db,
+		address: address,
+		weight:  weight,
+	}, recorder
+}
+
+func TestMysqlType_ReadWriteSplitting(t *testing.T) {
+	mysql, primaryRecorder := newFakeMysql()
+	replica1, replicaRecorder1 := newFakeReplica("replica1", 1)
+	replica2, replicaRecorder2 := newFakeReplica("replica2", 1)
+	mysql.replicas = []*Replica{replica1, replica2}
+	mysql.balancer = &RoundRobinBalancer{}
+
+	var dest []struct {
+		A string `json:"a"`
+	}
+	selectParams := &t_mysql.SelectParams{TableName: "table", Select: "a"}
+	go_test_.Equal(t, nil, mysql.Select(&dest, selectParams))
+	go_test_.Equal(t, nil, mysql.Select(&dest, selectParams))
+	go_test_.Equal(t, nil, mysql.SelectContext(ContextWithPrimary(context.Background()), &dest, selectParams))
+	_, err := mysql.RawExec("update `table` set `a` = 1")
+	go_test_.Equal(t, nil, err)
+	err = mysql.WithTransaction(context.Background(), func(tx *MysqlType) error {
+		return tx.Select(&dest, selectParams)
+	})
+	go_test_.Equal(t, nil, err)
+
+	go_test_.Equal(t, []string{"select a from `table` "}, replicaRecorder1.Stmts())
+	go_test_.Equal(t, []string{"select a from `table` "}, replicaRecorder2.Stmts())
+	go_test_.Equal(t, []string{
+		"select a from `table` ",
+		"update `table` set `a` = 1",
+		"begin",
+		"select a from `table` ",
+		"commit",
+	}, primaryRecorder.Stmts())
+}
+
+func TestBalancer(t *testing.T) {
+	replica1, _ := newFakeReplica("replica1", 1)
+	replica2, _ := newFakeReplica("replica2", 3)
+	replicas := []*Replica{replica1, replica2}
+
+	roundRobin := &RoundRobinBalancer{}
+	go_test_.Equal(t, "replica1", roundRobin.Pick(replicas).Address())
+	go_test_.Equal(t, "replica2", roundRobin.Pick(replicas).Address())
+	go_test_.Equal(t, "replica1", roundRobin.Pick(replicas).Address())
+
+	weighted := &WeightedBalancer{}
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		counts[weighted.Pick(replicas).Address()]++
+	}
+	go_test_.Equal(t, true, counts["replica2"] > 2*counts["replica1"])
+
+	go_test_.Equal(t, "replica1", (&LeastConnBalancer{}).Pick(replicas).Address())
+}
+
+func TestReplicaConfiguration_inherit(t *testing.T) {
+	replicaConfiguration := ReplicaConfiguration{
+		Configuration: t_mysql.Configuration{
+			Host:         "replica",
+			MaxOpenConns: 10,
+		},
+	}
+	result := replicaConfiguration.inherit(t_mysql.Configuration{
+		Host:         "primary",
+		Port:         3307,
+		Username:     "user",
+		Password:     "pass",
+		Database:     "db",
+		MaxOpenConns: 50,
+	})
+	go_test_.Equal(t, "replica", result.Host)
+	go_test_.Equal(t, 3307, result.Port)
+	go_test_.Equal(t, "user", result.Username)
+	go_test_.Equal(t, "pass", result.Password)
+	go_test_.Equal(t, "db", result.Database)
+	go_test_.Equal(t, 10, result.MaxOpenConns)
+}
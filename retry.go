@@ -0,0 +1,105 @@
+package go_mysql
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+var (
+	DEFAULT_TX_RETRY_MAX_ATTEMPTS int = 3
+	DEFAULT_TX_RETRY_BASE_DELAY       = 50 * time.Millisecond
+	DEFAULT_TX_RETRY_MAX_DELAY        = 2 * time.Second
+)
+
+const (
+	errNumLockWaitTimeout uint16 = 1205
+	errNumDeadlock        uint16 = 1213
+)
+
+type RetryOptions struct {
+	MaxAttempts int           // 包括第一次执行，默认 DEFAULT_TX_RETRY_MAX_ATTEMPTS
+	BaseDelay   time.Duration // 第一次重试前的等待时间，之后每次翻倍，默认 DEFAULT_TX_RETRY_BASE_DELAY
+	MaxDelay    time.Duration // 等待时间上限，默认 DEFAULT_TX_RETRY_MAX_DELAY
+	TxOptions   *TxOptions
+}
+
+// IsRetryableTxError 判断错误是否是死锁或者锁等待超时
+func IsRetryableTxError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == errNumDeadlock || mysqlErr.Number == errNumLockWaitTimeout
+}
+
+// WithRetryTransaction 同 WithTransaction，但遇到死锁（1213）或者锁等待超时（1205）时会重新执行整个事务。
+// 在事务实例上调用或者 ctx 中带有事务时不会重试，因为这两种错误需要整个外层事务重来，错误会原样返回给外层
+func (mc *MysqlType) WithRetryTransaction(ctx context.Context, opts *RetryOptions, fn func(tx *MysqlType) error) error {
+	if mc.ambientTx(ctx).tx != nil {
+		return mc.WithTransaction(ctx, fn)
+	}
+	if opts == nil {
+		opts = &RetryOptions{}
+	}
+	maxAttempts := DEFAULT_TX_RETRY_MAX_ATTEMPTS
+	if opts.MaxAttempts != 0 {
+		maxAttempts = opts.MaxAttempts
+	}
+	baseDelay := DEFAULT_TX_RETRY_BASE_DELAY
+	if opts.BaseDelay != 0 {
+		baseDelay = opts.BaseDelay
+	}
+	maxDelay := DEFAULT_TX_RETRY_MAX_DELAY
+	if opts.MaxDelay != 0 {
+		maxDelay = opts.MaxDelay
+	}
+
+	for attempt := 1; ; attempt++ {
+		tx, err := mc.runTransaction(ctx, opts.TxOptions, fn)
+		if err == nil || attempt >= maxAttempts || !IsRetryableTxError(err) {
+			return err
+		}
+
+		txInfo := ``
+		if tx != nil {
+			txInfo = tx.txInfo()
+		}
+		delay := retryDelay(baseDelay, maxDelay, attempt)
+		mc.logger.Warn(fmt.Sprintf(
+			`%stransaction failed, retrying. attempt: %d/%d, delay: %s, err: %v`,
+			txInfo,
+			attempt,
+			maxAttempts,
+			delay,
+			err,
+		))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryDelay 指数退避，实际等待时间在 [delay/2, delay) 之间随机
+func retryDelay(baseDelay time.Duration, maxDelay time.Duration, attempt int) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
@@ -0,0 +1,92 @@
+package go_mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	go_test_ "github.com/pefish/go-test"
+	"github.com/pkg/errors"
+)
+
+func TestMysqlType_WithRetryTransaction(t *testing.T) {
+	mysql_, recorder := newFakeMysql()
+	failures := 2
+	recorder.execErr = func(query string) error {
+		if query == "update `table` set `a` = 1" && failures > 0 {
+			failures--
+			return &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+		}
+		return nil
+	}
+	attempts := 0
+	err := mysql_.WithRetryTransaction(context.Background(), &RetryOptions{
+		BaseDelay: time.Millisecond,
+	}, func(tx *MysqlType) error {
+		attempts++
+		_, err := tx.RawExec("update `table` set `a` = 1")
+		return err
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, 3, attempts)
+	go_test_.Equal(t, "commit", recorder.Stmts()[len(recorder.Stmts())-1])
+
+	attempts = 0
+	err = mysql_.WithRetryTransaction(context.Background(), nil, func(tx *MysqlType) error {
+		attempts++
+		return errors.New("not retryable")
+	})
+	go_test_.NotEqual(t, nil, err)
+	go_test_.Equal(t, 1, attempts)
+
+	attempts = 0
+	err = mysql_.WithRetryTransaction(context.Background(), &RetryOptions{
+		MaxAttempts: 2,
+		BaseDelay:   time.Millisecond,
+	}, func(tx *MysqlType) error {
+		attempts++
+		return errors.WithStack(&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"})
+	})
+	go_test_.Equal(t, true, IsRetryableTxError(err))
+	go_test_.Equal(t, 2, attempts)
+}
+
+func Test_retryDelay(t *testing.T) {
+	for attempt := 1; attempt < 100; attempt++ {
+		delay := retryDelay(10*time.Millisecond, time.Second, attempt)
+		go_test_.Equal(t, true, delay >= 5*time.Millisecond && delay < time.Second)
+	}
+	delay := retryDelay(10*time.Millisecond, time.Second, 1)
+	go_test_.Equal(t, true, delay < 10*time.Millisecond)
+}
+
+func TestMysqlType_WithRetryTransaction_Ambient(t *testing.T) {
+	mysql_, recorder := newFakeMysql()
+	recorder.execErr = func(query string) error {
+		if query == "update `table` set `a` = 1" {
+			return &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+		}
+		return nil
+	}
+	attempts := 0
+	err := mysql_.WithTransaction(context.Background(), func(tx *MysqlType) error {
+		// ctx 中带有事务时不重试，错误交给外层事务
+		return mysql_.WithRetryTransaction(ContextWithTx(context.Background(), tx), &RetryOptions{
+			BaseDelay: time.Millisecond,
+		}, func(nested *MysqlType) error {
+			attempts++
+			_, err := nested.RawExec("update `table` set `a` = 1")
+			return err
+		})
+	})
+	go_test_.Equal(t, true, IsRetryableTxError(err))
+	go_test_.Equal(t, 1, attempts)
+	go_test_.Equal(t, []string{
+		"begin",
+		"savepoint sp_1",
+		"update `table` set `a` = 1",
+		"rollback to savepoint sp_1",
+		"rollback",
+	}, recorder.Stmts())
+}
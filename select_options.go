@@ -0,0 +1,132 @@
+package go_mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	"github.com/pkg/errors"
+)
+
+// OrderBy 排序项，Col 和 Expr 设置一个。Expr 原样使用，比如 field(`status`, ?, ?)，值放在 Args 里
+type OrderBy struct {
+	Col   string
+	Expr  string
+	Args  []interface{}
+	Order t_mysql.OrderType // asc 或者 desc，不区分大小写，为空使用数据库默认的 asc
+}
+
+func (o OrderBy) build() (sql string, args []interface{}, err error) {
+	order := strings.ToLower(strings.TrimSpace(string(o.Order)))
+	if order != "" && order != string(t_mysql.OrderType_ASC) && order != string(t_mysql.OrderType_DESC) {
+		return ``, nil, errors.Errorf(`Order direction <%s> is invalid.`, o.Order)
+	}
+	switch {
+	case o.Col != "" && o.Expr != "":
+		return ``, nil, errors.New(`Order by col and expr cannot be set together.`)
+	case o.Col != "":
+		sql = "`" + o.Col + "`"
+	case o.Expr != "":
+		sql = o.Expr
+	default:
+		return ``, nil, errors.New(`Order by col cannot be empty.`)
+	}
+	if order != "" {
+		sql += " " + order
+	}
+	return sql, o.Args, nil
+}
+
+// SelectOptions 在 SelectParams 的基础上支持多个排序项以及 offset
+type SelectOptions struct {
+	t_mysql.SelectParams
+	Orders []OrderBy // 排在 SelectParams.OrderBy 之后
+	Offset uint64
+}
+
+func (mysql *builderClass) buildSelectOptionsSql(selectOptions *SelectOptions, values ...interface{}) (
+	sql string,
+	paramArgs []interface{},
+	err error,
+) {
+	query := &selectQuery{
+		tableName: selectOptions.TableName,
+		select_:   selectOptions.Select,
+		where:     selectOptions.Where,
+		limit:     selectOptions.Limit,
+		offset:    selectOptions.Offset,
+	}
+	if selectOptions.OrderBy != nil {
+		query.orderBy = append(query.orderBy, OrderBy{
+			Col:   selectOptions.OrderBy.Col,
+			Order: selectOptions.OrderBy.Order,
+		})
+	}
+	query.orderBy = append(query.orderBy, selectOptions.Orders...)
+	return mysql.buildSelectQuery(query, values...)
+}
+
+func (mc *MysqlType) SelectWithOptions(
+	dest interface{},
+	selectOptions *SelectOptions,
+	values ...interface{},
+) error {
+	return mc.SelectWithOptionsContext(context.Background(), dest, selectOptions, values...)
+}
+
+func (mc *MysqlType) SelectWithOptionsContext(
+	ctx context.Context,
+	dest interface{},
+	selectOptions *SelectOptions,
+	values ...interface{},
+) error {
+	selectOptions.Select = mc.replaceIfStar(dest, selectOptions.Select)
+	sql, paramArgs, err := builder.buildSelectOptionsSql(selectOptions, values...)
+	if err != nil {
+		return err
+	}
+	return mc.rawSelect(ctx, dest, sql, paramArgs...)
+}
+
+func (mc *MysqlType) SelectFirstWithOptions(
+	dest interface{},
+	selectOptions *SelectOptions,
+	values ...interface{},
+) (
+	notFound bool,
+	err error,
+) {
+	return mc.SelectFirstWithOptionsContext(context.Background(), dest, selectOptions, values...)
+}
+
+func (mc *MysqlType) SelectFirstWithOptionsContext(
+	ctx context.Context,
+	dest interface{},
+	selectOptions *SelectOptions,
+	values ...interface{},
+) (
+	notFound bool,
+	err error,
+) {
+	selectOptions.Select = mc.replaceIfStar(dest, selectOptions.Select)
+	sql, paramArgs, err := builder.buildSelectOptionsSql(selectOptions, values...)
+	if err != nil {
+		return true, err
+	}
+	return mc.rawSelectFirst(ctx, dest, sql, paramArgs...)
+}
+
+func (mysql *builderClass) buildOrderBy(orders []OrderBy) (sql string, args []interface{}, err error) {
+	items := make([]string, 0, len(orders))
+	args = make([]interface{}, 0)
+	for _, order := range orders {
+		item, itemArgs, err := order.build()
+		if err != nil {
+			return ``, nil, err
+		}
+		items = append(items, item)
+		args = append(args, itemArgs...)
+	}
+	return fmt.Sprintf("order by %s", strings.Join(items, ", ")), args, nil
+}
@@ -0,0 +1,77 @@
+package go_mysql
+
+import (
+	"context"
+	"testing"
+
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestBuilderClass_buildSelectOptionsSql(t *testing.T) {
+	sql, args, err := builder.buildSelectOptionsSql(&SelectOptions{
+		SelectParams: t_mysql.SelectParams{
+			TableName: "tasks",
+			Select:    "*",
+			Where:     map[string]interface{}{"owner": 7},
+			OrderBy:   &t_mysql.OrderByType{Col: "priority", Order: "DESC"},
+			Limit:     20,
+		},
+		Orders: []OrderBy{
+			{Expr: "field(`status`, ?, ?)", Args: []interface{}{"open", "closed"}},
+			{Col: "id", Order: t_mysql.OrderType_ASC},
+		},
+		Offset: 40,
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "select * from `tasks` where `owner` = ? order by `priority` desc, field(`status`, ?, ?), `id` asc limit 20 offset 40", sql)
+	go_test_.Equal(t, []interface{}{"7", "open", "closed"}, args)
+
+	_, _, err = builder.buildSelectOptionsSql(&SelectOptions{
+		SelectParams: t_mysql.SelectParams{TableName: "tasks", Select: "*"},
+		Orders:       []OrderBy{{Col: "id", Order: "desc; drop table tasks"}},
+	})
+	go_test_.Equal(t, "Order direction <desc; drop table tasks> is invalid.", err.Error())
+
+	_, _, err = builder.buildSelectSql(&t_mysql.SelectParams{
+		TableName: "tasks",
+		Select:    "*",
+		OrderBy:   &t_mysql.OrderByType{Col: "id", Order: "random"},
+	})
+	go_test_.Equal(t, "Order direction <random> is invalid.", err.Error())
+
+	_, _, err = builder.buildSelectOptionsSql(&SelectOptions{
+		SelectParams: t_mysql.SelectParams{TableName: "tasks", Select: "*"},
+		Orders:       []OrderBy{{Col: "id", Expr: "id"}},
+	})
+	go_test_.Equal(t, "Order by col and expr cannot be set together.", err.Error())
+}
+
+func TestMysqlType_SelectWithOptions(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+	var tasks []struct {
+		Id uint64 `json:"id"`
+	}
+	err := mysql.SelectWithOptionsContext(context.Background(), &tasks, &SelectOptions{
+		SelectParams: t_mysql.SelectParams{TableName: "tasks", Select: "*", Limit: 10},
+		Orders: []OrderBy{
+			{Col: "priority", Order: t_mysql.OrderType_DESC},
+			{Col: "id"},
+		},
+		Offset: 30,
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "select `id` from `tasks`  order by `priority` desc, `id` limit 10 offset 30", recorder.Stmts()[0])
+
+	var task struct {
+		Id uint64 `json:"id"`
+	}
+	notFound, err := mysql.SelectFirstWithOptions(&task, &SelectOptions{
+		SelectParams: t_mysql.SelectParams{TableName: "tasks", Select: "*", Limit: 1},
+		Orders:       []OrderBy{{Col: "id", Order: t_mysql.OrderType_DESC}},
+		Offset:       5,
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, true, notFound)
+	go_test_.Equal(t, "select `id` from `tasks`  order by `id` desc limit 1 offset 5", recorder.Stmts()[1])
+}
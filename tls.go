@@ -0,0 +1,95 @@
+package go_mysql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// TLSConfiguration 证书可以是文件路径或者 PEM 内容，同时设置时使用文件
+type TLSConfiguration struct {
+	CAFile   string
+	CAPem    []byte
+	CertFile string // 客户端证书，双向认证时使用，需要和 key 一起设置
+	CertPem  []byte
+	KeyFile  string
+	KeyPem   []byte
+
+	ServerName               string // 默认使用连接的 host
+	MinVersion               uint16 // tls.VersionTLS12 等，默认 tls.VersionTLS12
+	InsecureSkipVerify       bool   // 不校验服务端证书，相当于 tls=skip-verify
+	AllowFallbackToPlaintext bool   // 服务端不支持 TLS 时使用明文连接，相当于 tls=preferred
+}
+
+func readPem(file string, pem []byte) ([]byte, error) {
+	if file == "" {
+		return pem, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return content, nil
+}
+
+func (t *TLSConfiguration) build() (*tls.Config, error) {
+	minVersion := t.MinVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	config := &tls.Config{
+		ServerName:         t.ServerName,
+		MinVersion:         minVersion,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	caPem, err := readPem(t.CAFile, t.CAPem)
+	if err != nil {
+		return nil, err
+	}
+	if len(caPem) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, errors.New(`TLS CA is invalid.`)
+		}
+		config.RootCAs = pool
+	}
+
+	certPem, err := readPem(t.CertFile, t.CertPem)
+	if err != nil {
+		return nil, err
+	}
+	keyPem, err := readPem(t.KeyFile, t.KeyPem)
+	if err != nil {
+		return nil, err
+	}
+	if len(certPem) > 0 || len(keyPem) > 0 {
+		if len(certPem) == 0 || len(keyPem) == 0 {
+			return nil, errors.New(`TLS cert and key must be set together.`)
+		}
+		cert, err := tls.X509KeyPair(certPem, keyPem)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// register 以唯一的名字注册到驱动，返回的名字用于连接参数 tls=<name>
+func (t *TLSConfiguration) register() (string, error) {
+	config, err := t.build()
+	if err != nil {
+		return ``, err
+	}
+	name := `go-mysql-` + uuid.New().String()
+	err = mysql.RegisterTLSConfig(name, config)
+	if err != nil {
+		return ``, errors.WithStack(err)
+	}
+	return name, nil
+}
@@ -0,0 +1,90 @@
+package go_mysql
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	go_test_ "github.com/pefish/go-test"
+)
+
+func newTestCertPem(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	go_test_.Equal(t, nil, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	go_test_.Equal(t, nil, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	go_test_.Equal(t, nil, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestTLSConfiguration_build(t *testing.T) {
+	certPem, keyPem := newTestCertPem(t)
+
+	config, err := (&TLSConfiguration{
+		CAPem:      certPem,
+		CertPem:    certPem,
+		KeyPem:     keyPem,
+		ServerName: "db.example.com",
+	}).build()
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	go_test_.Equal(t, "db.example.com", config.ServerName)
+	go_test_.Equal(t, true, config.RootCAs != nil)
+	go_test_.Equal(t, 1, len(config.Certificates))
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	go_test_.Equal(t, nil, os.WriteFile(caFile, certPem, 0600))
+	config, err = (&TLSConfiguration{
+		CAFile:     caFile,
+		MinVersion: tls.VersionTLS13,
+	}).build()
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+	go_test_.Equal(t, 0, len(config.Certificates))
+
+	_, err = (&TLSConfiguration{CAPem: []byte("bad")}).build()
+	go_test_.Equal(t, "TLS CA is invalid.", err.Error())
+	_, err = (&TLSConfiguration{CertPem: certPem}).build()
+	go_test_.Equal(t, "TLS cert and key must be set together.", err.Error())
+	_, err = (&TLSConfiguration{CAFile: filepath.Join(dir, "missing.pem")}).build()
+	go_test_.Equal(t, true, err != nil)
+}
+
+func TestTLSConfiguration_register(t *testing.T) {
+	certPem, _ := newTestCertPem(t)
+	name, err := (&TLSConfiguration{CAPem: certPem}).register()
+	go_test_.Equal(t, nil, err)
+
+	config, err := mysql.ParseDSN("root:pass@tcp(127.0.0.1:3306)/test?tls=" + name)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "127.0.0.1", config.TLS.ServerName)
+
+	c, err := newConnector(config, []string{"127.0.0.1:3306"}, nil)
+	go_test_.Equal(t, nil, err)
+	c.tlsConfigName = name
+	c.close()
+	_, err = mysql.ParseDSN("root:pass@tcp(127.0.0.1:3306)/test?tls=" + name)
+	go_test_.Equal(t, true, err != nil)
+}
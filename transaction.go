@@ -0,0 +1,193 @@
+package go_mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+
+	i_mysql "github.com/pefish/go-interface/i-mysql"
+	"github.com/pefish/go-mysql/sqlx"
+	"github.com/pkg/errors"
+)
+
+// WithTransaction 在事务中执行 fn。fn 返回 nil 则提交，返回错误或者 panic 则回滚（panic 会在回滚后重新抛出）
+func (mc *MysqlType) WithTransaction(ctx context.Context, fn func(tx *MysqlType) error) error {
+	_, err := mc.runTransaction(ctx, nil, fn)
+	return err
+}
+
+// runTransaction 返回本次使用的事务实例，开启事务失败时为 nil
+func (mc *MysqlType) runTransaction(ctx context.Context, opts *TxOptions, fn func(tx *MysqlType) error) (tx *MysqlType, err error) {
+	tx, err = mc.beginTxWithOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.rollbackQuietly()
+			panic(r)
+		}
+	}()
+
+	err = fn(tx)
+	if err != nil {
+		tx.rollbackQuietly()
+		return tx, err
+	}
+	return tx, tx.Commit()
+}
+
+func (mc *MysqlType) rollbackQuietly() {
+	err := mc.Rollback()
+	if err != nil && !errors.Is(err, ErrTxDone) {
+		mc.logger.Error(fmt.Sprintf(`%srollback failed. err: %v`, mc.txInfo(), err))
+	}
+}
+
+// TxOptions 开启事务时的选项
+type TxOptions struct {
+	// 隔离级别，支持 sql.LevelReadCommitted、sql.LevelRepeatableRead、sql.LevelSerializable，零值表示使用会话默认值
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// 对应 start transaction with consistent snapshot，只在 repeatable read 下有意义
+	ConsistentSnapshot bool
+}
+
+func (opts *TxOptions) validate() error {
+	if opts == nil {
+		return nil
+	}
+	switch opts.Isolation {
+	case sql.LevelDefault, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSerializable:
+	default:
+		return errors.Errorf(`Isolation level <%s> not supported.`, opts.Isolation)
+	}
+	if opts.ConsistentSnapshot && opts.Isolation != sql.LevelDefault && opts.Isolation != sql.LevelRepeatableRead {
+		return errors.New(`Consistent snapshot requires repeatable read isolation level.`)
+	}
+	return nil
+}
+
+func (opts *TxOptions) begin(ctx context.Context, db *sqlx.DB) (*sqlx.Tx, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		return db.BeginTxx(ctx, nil)
+	}
+	if !opts.ConsistentSnapshot {
+		return db.BeginTxx(ctx, &sql.TxOptions{
+			Isolation: opts.Isolation,
+			ReadOnly:  opts.ReadOnly,
+		})
+	}
+
+	// 驱动不支持 with consistent snapshot，所以先提交驱动开启的空事务，再在同一个连接上手动开启，
+	// 之后的 commit/rollback 作用在新事务上
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	sqls := []string{`commit`}
+	if opts.Isolation == sql.LevelRepeatableRead {
+		sqls = append(sqls, `set transaction isolation level repeatable read`)
+	}
+	startSql := `start transaction with consistent snapshot`
+	if opts.ReadOnly {
+		startSql += `, read only`
+	}
+	sqls = append(sqls, startSql)
+	for _, sql_ := range sqls {
+		_, err = tx.ExecContext(ctx, sql_)
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, errors.WithStack(err)
+		}
+	}
+	return tx, nil
+}
+
+// BeginWithOptions 按照 opts 开启事务。不能在事务实例上调用
+func (mc *MysqlType) BeginWithOptions(ctx context.Context, opts *TxOptions) (i_mysql.IMysql, error) {
+	return mc.beginTxWithOptions(ctx, opts)
+}
+
+const (
+	txStateActive int32 = iota
+	txStateCommitted
+	txStateRolledBack
+)
+
+// txDone 自己或者任意一层外层事务结束了都算结束
+func (mc *MysqlType) txDone() bool {
+	for tx := mc; tx != nil; tx = tx.parent {
+		if atomic.LoadInt32(&tx.state) != txStateActive {
+			return true
+		}
+	}
+	return false
+}
+
+// finishTx 最外层事务结束时调用，通过 CAS 决定由谁结束事务，只有返回 true 的调用方可以执行回调
+func (mc *MysqlType) finishTx(state int32) bool {
+	if !atomic.CompareAndSwapInt32(&mc.state, txStateActive, state) {
+		return false
+	}
+	mc.txTracker.remove(mc)
+	if mc.abort != nil {
+		mc.abort()
+	}
+	return true
+}
+
+// abortableContext 事务中执行语句使用，事务被强制回滚时取消，驱动会断开连接中断语句
+func (mc *MysqlType) abortableContext(ctx context.Context) (context.Context, func()) {
+	if mc.abortCtx == nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stopAfter := context.AfterFunc(mc.abortCtx, cancel)
+	return ctx, func() {
+		stopAfter()
+		cancel()
+	}
+}
+
+// forceRollback 看门狗使用，不经过 enterTx，正在执行的语句（比如在等锁）会被中断。
+// 用户同时调用了 Commit/Rollback 的话由先结束 sql.Tx 的一方执行回调，这里返回 ErrTxDone
+func (mc *MysqlType) forceRollback() error {
+	if mc.txDone() {
+		return ErrTxDone
+	}
+	mc.abort()
+	err := mc.tx.Rollback()
+	if errors.Is(err, sql.ErrTxDone) {
+		return ErrTxDone
+	}
+	if !mc.finishTx(txStateRolledBack) {
+		return ErrTxDone
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	mc.hooks.fireRollback(mc)
+	return nil
+}
+
+// enterTx 事务实例在使用连接前调用，检查事务状态以及是否被并发使用，返回的函数用于释放。非事务实例直接通过
+func (mc *MysqlType) enterTx() (leave func(), err error) {
+	if mc.tx == nil {
+		return func() {}, nil
+	}
+	if mc.txDone() {
+		return nil, ErrTxDone
+	}
+	if !atomic.CompareAndSwapInt32(mc.busy, 0, 1) {
+		return nil, ErrTxConcurrentUse
+	}
+	return func() {
+		atomic.StoreInt32(mc.busy, 0)
+	}, nil
+}
@@ -0,0 +1,145 @@
+package go_mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestMysqlType_WithTransaction(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+	err := mysql.WithTransaction(context.Background(), func(tx *MysqlType) error {
+		_, err := tx.RawExec("update `table` set `a` = 1")
+		return err
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, []string{"begin", "update `table` set `a` = 1", "commit"}, recorder.Stmts())
+
+	mysql, recorder = newFakeMysql()
+	errTest := errors.New("test")
+	err = mysql.WithTransaction(context.Background(), func(tx *MysqlType) error {
+		return errTest
+	})
+	go_test_.Equal(t, errTest, err)
+	go_test_.Equal(t, []string{"begin", "rollback"}, recorder.Stmts())
+
+	mysql, recorder = newFakeMysql()
+	func() {
+		defer func() {
+			go_test_.Equal(t, "boom", recover())
+		}()
+		_ = mysql.WithTransaction(context.Background(), func(tx *MysqlType) error {
+			panic("boom")
+		})
+	}()
+	go_test_.Equal(t, []string{"begin", "rollback"}, recorder.Stmts())
+}
+
+func TestMysqlType_BeginSavepoint(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+	tx, err := mysql.Begin()
+	go_test_.Equal(t, nil, err)
+
+	nested, err := tx.Begin()
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, nil, nested.Commit())
+
+	nested, err = tx.Begin()
+	go_test_.Equal(t, nil, err)
+	deeper, err := nested.Begin()
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, nil, deeper.Rollback())
+	go_test_.Equal(t, nil, nested.Rollback())
+
+	go_test_.Equal(t, nil, tx.Commit())
+	go_test_.Equal(t, []string{
+		"begin",
+		"savepoint sp_1",
+		"release savepoint sp_1",
+		"savepoint sp_2",
+		"savepoint sp_3",
+		"rollback to savepoint sp_3",
+		"rollback to savepoint sp_2",
+		"commit",
+	}, recorder.Stmts())
+}
+
+func TestMysqlType_BeginWithOptions(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+	tx, err := mysql.BeginWithOptions(context.Background(), &TxOptions{
+		Isolation: sql.LevelSerializable,
+		ReadOnly:  true,
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, nil, tx.Commit())
+	go_test_.Equal(t, []string{"begin Serializable read only", "commit"}, recorder.Stmts())
+
+	mysql, recorder = newFakeMysql()
+	tx, err = mysql.BeginWithOptions(context.Background(), &TxOptions{
+		Isolation:          sql.LevelRepeatableRead,
+		ReadOnly:           true,
+		ConsistentSnapshot: true,
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, nil, tx.Rollback())
+	go_test_.Equal(t, []string{
+		"begin",
+		"commit",
+		"set transaction isolation level repeatable read",
+		"start transaction with consistent snapshot, read only",
+		"rollback",
+	}, recorder.Stmts())
+
+	_, err = mysql.BeginWithOptions(context.Background(), &TxOptions{
+		Isolation:          sql.LevelReadCommitted,
+		ConsistentSnapshot: true,
+	})
+	go_test_.NotEqual(t, nil, err)
+	_, err = mysql.BeginWithOptions(context.Background(), &TxOptions{
+		Isolation: sql.LevelLinearizable,
+	})
+	go_test_.NotEqual(t, nil, err)
+}
+
+func TestMysqlType_TxState(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+	go_test_.Equal(t, ErrNotInTransaction, mysql.Commit())
+	go_test_.Equal(t, ErrNotInTransaction, mysql.Rollback())
+
+	tx, err := mysql.Begin()
+	go_test_.Equal(t, nil, err)
+	nested, err := tx.Begin()
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, nil, tx.Commit())
+	go_test_.Equal(t, ErrTxDone, tx.Commit())
+	go_test_.Equal(t, ErrTxDone, tx.Rollback())
+	go_test_.Equal(t, ErrTxDone, nested.Commit())
+	_, err = tx.RawExec("update `table` set `a` = 1")
+	go_test_.Equal(t, ErrTxDone, err)
+	_, err = tx.Begin()
+	go_test_.Equal(t, ErrTxDone, err)
+
+	tx, err = mysql.Begin()
+	go_test_.Equal(t, nil, err)
+	nested, err = tx.Begin()
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, nil, nested.Rollback())
+	_, err = nested.RawExec("update `table` set `a` = 1")
+	go_test_.Equal(t, ErrTxDone, err)
+	_, err = tx.RawExec("update `table` set `a` = 1")
+	go_test_.Equal(t, nil, err)
+
+	atomic.StoreInt32(tx.(*MysqlType).busy, 1)
+	_, err = tx.RawExec("update `table` set `a` = 2")
+	go_test_.Equal(t, ErrTxConcurrentUse, err)
+	atomic.StoreInt32(tx.(*MysqlType).busy, 0)
+
+	tx.Close()
+	go_test_.Equal(t, ErrTxDone, tx.Rollback())
+	stmts := recorder.Stmts()
+	go_test_.Equal(t, "rollback", stmts[len(stmts)-1])
+}
@@ -0,0 +1,111 @@
+package go_mysql
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var DEFAULT_TX_WATCHDOG_INTERVAL = 10 * time.Second
+
+// txTracker 记录连接池上所有未结束的最外层事务
+type txTracker struct {
+	txs sync.Map // txId -> *MysqlType
+}
+
+func (t *txTracker) add(tx *MysqlType) {
+	if t == nil {
+		return
+	}
+	t.txs.Store(tx.txId, tx)
+}
+
+func (t *txTracker) remove(tx *MysqlType) {
+	if t == nil {
+		return
+	}
+	t.txs.Delete(tx.txId)
+}
+
+type TxWatchdogOptions struct {
+	WarnAfter     time.Duration // 事务开启超过这个时间就打印日志（每个事务只打印一次），必填
+	RollbackAfter time.Duration // 事务开启超过这个时间就强制回滚，正在执行的语句会被中断。0 表示不回滚
+	Interval      time.Duration // 检查间隔，默认 DEFAULT_TX_WATCHDOG_INTERVAL
+}
+
+// StartTxWatchdog 启动后台协程检查长时间未结束的事务，打印事务 id 以及 Begin 时的调用栈。
+// 重复调用会替换之前的配置，Close 时自动停止
+func (mc *MysqlType) StartTxWatchdog(opts TxWatchdogOptions) error {
+	if mc.tx != nil {
+		return errors.New(`Watchdog must be started on a non-transaction instance.`)
+	}
+	if opts.WarnAfter <= 0 {
+		return errors.New(`WarnAfter must be greater than 0.`)
+	}
+	interval := DEFAULT_TX_WATCHDOG_INTERVAL
+	if opts.Interval != 0 {
+		interval = opts.Interval
+	}
+
+	mc.StopTxWatchdog()
+	stop := make(chan struct{})
+	mc.watchdogLock.Lock()
+	mc.watchdogStop = stop
+	mc.watchdogLock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				mc.checkLongTxs(opts)
+			}
+		}
+	}()
+	return nil
+}
+
+func (mc *MysqlType) StopTxWatchdog() {
+	mc.watchdogLock.Lock()
+	defer mc.watchdogLock.Unlock()
+	if mc.watchdogStop != nil {
+		close(mc.watchdogStop)
+		mc.watchdogStop = nil
+	}
+}
+
+func (mc *MysqlType) checkLongTxs(opts TxWatchdogOptions) {
+	now := time.Now()
+	mc.txTracker.txs.Range(func(_, value interface{}) bool {
+		tx := value.(*MysqlType)
+		openFor := now.Sub(tx.beganAt)
+		if opts.RollbackAfter > 0 && openFor >= opts.RollbackAfter {
+			mc.logger.Error(fmt.Sprintf(
+				"%stransaction open for %s, force rollback. begin stack:\n%s",
+				tx.txInfo(),
+				openFor,
+				tx.beginStack,
+			))
+			err := tx.forceRollback()
+			if err != nil && !errors.Is(err, ErrTxDone) {
+				mc.logger.Error(fmt.Sprintf(`%sforce rollback failed. err: %v`, tx.txInfo(), err))
+			}
+			return true
+		}
+		if openFor >= opts.WarnAfter && atomic.CompareAndSwapInt32(&tx.leakWarned, 0, 1) {
+			mc.logger.Warn(fmt.Sprintf(
+				"%stransaction open for %s. begin stack:\n%s",
+				tx.txInfo(),
+				openFor,
+				tx.beginStack,
+			))
+		}
+		return true
+	})
+}
@@ -0,0 +1,113 @@
+package go_mysql
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestMysqlType_StartTxWatchdog(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+	go_test_.NotEqual(t, nil, mysql.StartTxWatchdog(TxWatchdogOptions{}))
+
+	tx, err := mysql.Begin()
+	go_test_.Equal(t, nil, err)
+	committed, err := mysql.Begin()
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, nil, committed.Commit())
+
+	err = mysql.StartTxWatchdog(TxWatchdogOptions{
+		WarnAfter:     time.Millisecond,
+		RollbackAfter: 20 * time.Millisecond,
+		Interval:      time.Millisecond,
+	})
+	go_test_.Equal(t, nil, err)
+	defer mysql.StopTxWatchdog()
+
+	deadline := time.Now().Add(time.Second)
+	for !tx.(*MysqlType).txDone() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	go_test_.Equal(t, ErrTxDone, tx.Commit())
+	go_test_.Equal(t, []string{"begin", "begin", "commit", "rollback"}, recorder.Stmts())
+	go_test_.Equal(t, int32(1), atomic.LoadInt32(&tx.(*MysqlType).leakWarned))
+}
+
+func TestMysqlType_StartTxWatchdog_Busy(t *testing.T) {
+	mysql, recorder := newFakeMysql()
+	recorder.block = func(query string) bool {
+		return query == "update `table` set `a` = 1"
+	}
+	tx, err := mysql.Begin()
+	go_test_.Equal(t, nil, err)
+	rolledBack := int32(0)
+	tx.(*MysqlType).OnRollback(func() {
+		atomic.StoreInt32(&rolledBack, 1)
+	})
+
+	err = mysql.StartTxWatchdog(TxWatchdogOptions{
+		WarnAfter:     time.Millisecond,
+		RollbackAfter: 20 * time.Millisecond,
+		Interval:      time.Millisecond,
+	})
+	go_test_.Equal(t, nil, err)
+	defer mysql.StopTxWatchdog()
+
+	// 等锁中的语句被中断，事务被回滚
+	_, err = tx.RawExec("update `table` set `a` = 1")
+	go_test_.Equal(t, true, errors.Is(err, context.Canceled))
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&rolledBack) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	go_test_.Equal(t, int32(1), atomic.LoadInt32(&rolledBack))
+	go_test_.Equal(t, true, tx.(*MysqlType).txDone())
+	go_test_.Equal(t, ErrTxDone, tx.Rollback())
+	go_test_.Equal(t, []string{"begin", "update `table` set `a` = 1", "rollback"}, recorder.Stmts())
+}
+
+func TestMysqlType_forceRollback_Race(t *testing.T) {
+	// 用户提交时看门狗强制回滚，提交已经生效，只能执行提交的回调
+	mysql, recorder := newFakeMysql()
+	tx, err := mysql.Begin()
+	go_test_.Equal(t, nil, err)
+	committed, rolledBack := false, false
+	tx.(*MysqlType).OnCommit(func() { committed = true })
+	tx.(*MysqlType).OnRollback(func() { rolledBack = true })
+	var forceErr error
+	recorder.execErr = func(query string) error {
+		if query == "commit" {
+			forceErr = tx.(*MysqlType).forceRollback()
+		}
+		return nil
+	}
+	go_test_.Equal(t, nil, tx.Commit())
+	go_test_.Equal(t, ErrTxDone, forceErr)
+	go_test_.Equal(t, true, committed)
+	go_test_.Equal(t, false, rolledBack)
+	go_test_.Equal(t, txStateCommitted, atomic.LoadInt32(&tx.(*MysqlType).state))
+
+	// 看门狗先结束了事务，用户的提交不能执行提交的回调
+	mysql, recorder = newFakeMysql()
+	tx, err = mysql.Begin()
+	go_test_.Equal(t, nil, err)
+	committed, rolledBack = false, false
+	tx.(*MysqlType).OnCommit(func() { committed = true })
+	tx.(*MysqlType).OnRollback(func() { rolledBack = true })
+	var commitErr error
+	recorder.execErr = func(query string) error {
+		if query == "rollback" {
+			commitErr = tx.Commit()
+		}
+		return nil
+	}
+	go_test_.Equal(t, nil, tx.(*MysqlType).forceRollback())
+	go_test_.Equal(t, ErrTxDone, commitErr)
+	go_test_.Equal(t, false, committed)
+	go_test_.Equal(t, true, rolledBack)
+	go_test_.Equal(t, txStateRolledBack, atomic.LoadInt32(&tx.(*MysqlType).state))
+}
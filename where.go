@@ -0,0 +1,222 @@
+package go_mysql
+
+import (
+	"reflect"
+	"strings"
+
+	go_format "github.com/pefish/go-format"
+	"github.com/pkg/errors"
+)
+
+// Condition where map 的值，所有的值都作为参数绑定，比如 map[string]interface{}{"amount": Gt(100)}
+type Condition interface {
+	// buildCondition 返回列名之后的操作符、值以及参数，skip 为 true 表示忽略该条件
+	buildCondition() (op string, val string, args []interface{}, skip bool)
+}
+
+type compareCondition struct {
+	op    string
+	value interface{}
+}
+
+func (c compareCondition) buildCondition() (string, string, []interface{}, bool) {
+	return c.op, "?", []interface{}{go_format.ToString(c.value)}, false
+}
+
+// Gt `col` > ?
+func Gt(value interface{}) Condition {
+	return compareCondition{op: ">", value: value}
+}
+
+// Gte `col` >= ?
+func Gte(value interface{}) Condition {
+	return compareCondition{op: ">=", value: value}
+}
+
+// Lt `col` < ?
+func Lt(value interface{}) Condition {
+	return compareCondition{op: "<", value: value}
+}
+
+// Lte `col` <= ?
+func Lte(value interface{}) Condition {
+	return compareCondition{op: "<=", value: value}
+}
+
+// Ne `col` != ?
+func Ne(value interface{}) Condition {
+	return compareCondition{op: "!=", value: value}
+}
+
+// Like `col` like ?，通配符需要包含在 pattern 里
+func Like(pattern string) Condition {
+	return compareCondition{op: "like", value: pattern}
+}
+
+// NotLike `col` not like ?
+func NotLike(pattern string) Condition {
+	return compareCondition{op: "not like", value: pattern}
+}
+
+type betweenCondition struct {
+	from interface{}
+	to   interface{}
+}
+
+func (c betweenCondition) buildCondition() (string, string, []interface{}, bool) {
+	return "between", "? and ?", []interface{}{go_format.ToString(c.from), go_format.ToString(c.to)}, false
+}
+
+// Between `col` between ? and ?
+func Between(from, to interface{}) Condition {
+	return betweenCondition{from: from, to: to}
+}
+
+type notInCondition struct {
+	values interface{}
+}
+
+func (c notInCondition) buildCondition() (string, string, []interface{}, bool) {
+	vals := make([]string, 0)
+	args := make([]interface{}, 0)
+	value_ := reflect.ValueOf(c.values)
+	if value_.Kind() != reflect.Slice {
+		return "not in", "(?)", []interface{}{go_format.ToString(c.values)}, false
+	}
+	for i := 0; i < value_.Len(); i++ {
+		vals = append(vals, "?")
+		args = append(args, go_format.ToString(value_.Index(i).Interface()))
+	}
+	// not in 空集合恒为真，和空 slice 的 in 一样忽略
+	if len(vals) == 0 {
+		return "", "", nil, true
+	}
+	return "not in", "(" + strings.Join(vals, ",") + ")", args, false
+}
+
+// NotIn `col` not in (?,?)，values 是 slice，为空时忽略该条件
+func NotIn(values interface{}) Condition {
+	return notInCondition{values: values}
+}
+
+type nullCondition struct {
+	op string
+}
+
+func (c nullCondition) buildCondition() (string, string, []interface{}, bool) {
+	return c.op, "null", nil, false
+}
+
+// IsNull `col` is null
+func IsNull() Condition {
+	return nullCondition{op: "is"}
+}
+
+// IsNotNull `col` is not null
+func IsNotNull() Condition {
+	return nullCondition{op: "is not"}
+}
+
+type rawCondition struct {
+	sql  string
+	args []interface{}
+}
+
+func (c rawCondition) buildCondition() (string, string, []interface{}, bool) {
+	return strings.TrimSpace(c.sql), "", c.args, false
+}
+
+// Raw 列名之后的 sql 片段，值用 ? 占位，比如 Raw("> date_sub(now(), interval ? day)", 7)
+func Raw(sql string, args ...interface{}) Condition {
+	return rawCondition{sql: sql, args: args}
+}
+
+// WhereNode And、Or、Not 组成的条件树，可以直接作为 Where，也可以任意嵌套。
+// 子节点可以是 map[string]interface{}（多个键之间是 and）、struct 或者 *WhereNode
+type WhereNode struct {
+	op    string // and、or、not
+	items []interface{}
+}
+
+// And 子条件之间是 and
+func And(items ...interface{}) *WhereNode {
+	return &WhereNode{op: "and", items: items}
+}
+
+// Or 子条件之间是 or
+func Or(items ...interface{}) *WhereNode {
+	return &WhereNode{op: "or", items: items}
+}
+
+// Not 对子条件取反
+func Not(item interface{}) *WhereNode {
+	return &WhereNode{op: "not", items: []interface{}{item}}
+}
+
+// build terms 是顶层条件的个数，大于 1 时嵌套在其他节点里需要加括号
+func (n *WhereNode) build(builder *builderClass) (sql string, args []interface{}, terms int, err error) {
+	parts := make([]string, 0, len(n.items))
+	args = make([]interface{}, 0)
+	for _, item := range n.items {
+		itemSql, itemArgs, itemTerms, err := builder.buildWhereItem(item)
+		if err != nil {
+			return ``, nil, 0, err
+		}
+		if itemSql == "" {
+			continue
+		}
+		if itemTerms > 1 {
+			itemSql = "(" + itemSql + ")"
+		}
+		parts = append(parts, itemSql)
+		args = append(args, itemArgs...)
+	}
+	if len(parts) == 0 {
+		return ``, args, 0, nil
+	}
+	if n.op == "not" {
+		sql = parts[0]
+		if !strings.HasPrefix(sql, "(") {
+			sql = "(" + sql + ")"
+		}
+		return "not " + sql, args, 1, nil
+	}
+	return strings.Join(parts, " "+n.op+" "), args, len(parts), nil
+}
+
+// rawWhere 带参数的 where sql 片段
+type rawWhere struct {
+	sql  string
+	args []interface{}
+}
+
+func (mysql *builderClass) buildWhereItem(item interface{}) (sql string, args []interface{}, terms int, err error) {
+	switch v := item.(type) {
+	case nil:
+		return ``, nil, 0, nil
+	case *WhereNode:
+		return v.build(mysql)
+	case map[string]interface{}:
+		cols, _, _, _ := mysql.buildFromMap(v)
+		args, sql = mysql.buildWhereFromMap(v)
+		return sql, args, len(cols), nil
+	case []map[string]interface{}:
+		items := make([]interface{}, 0, len(v))
+		for _, ele := range v {
+			items = append(items, ele)
+		}
+		return Or(items...).build(mysql)
+	case rawWhere:
+		// 不知道里面有几个条件，当作多个处理加上括号
+		return v.sql, v.args, 2, nil
+	}
+	if reflect.Indirect(reflect.ValueOf(item)).Kind() != reflect.Struct {
+		return ``, nil, 0, errors.New(`Where item type error.`)
+	}
+	map_ := make(map[string]interface{})
+	err = mysql.structToMap(item, map_)
+	if err != nil {
+		return ``, nil, 0, err
+	}
+	return mysql.buildWhereItem(map_)
+}
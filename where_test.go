@@ -0,0 +1,130 @@
+package go_mysql
+
+import (
+	"testing"
+
+	t_mysql "github.com/pefish/go-interface/t-mysql"
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestCondition(t *testing.T) {
+	tests := []struct {
+		condition Condition
+		sql       string
+		args      []interface{}
+	}{
+		{Gt(100), "`c` > ?", []interface{}{"100"}},
+		{Gte(100), "`c` >= ?", []interface{}{"100"}},
+		{Lt(1.5), "`c` < ?", []interface{}{"1.5"}},
+		{Lte(2), "`c` <= ?", []interface{}{"2"}},
+		{Ne("a"), "`c` != ?", []interface{}{"a"}},
+		{Like("%a%"), "`c` like ?", []interface{}{"%a%"}},
+		{NotLike("a%"), "`c` not like ?", []interface{}{"a%"}},
+		{Between(1, 10), "`c` between ? and ?", []interface{}{"1", "10"}},
+		{NotIn([]int{1, 2}), "`c` not in (?,?)", []interface{}{"1", "2"}},
+		{NotIn(3), "`c` not in (?)", []interface{}{"3"}},
+		{NotIn([]int{}), "", []interface{}{}},
+		{IsNull(), "`c` is null", []interface{}{}},
+		{IsNotNull(), "`c` is not null", []interface{}{}},
+		{Raw("> date_sub(now(), interval ? day)", 7), "`c` > date_sub(now(), interval ? day)", []interface{}{7}},
+	}
+	builder := builderClass{}
+	for _, test := range tests {
+		args, sql := builder.buildWhereFromMap(map[string]interface{}{
+			"c": test.condition,
+		})
+		go_test_.Equal(t, test.sql, sql)
+		go_test_.Equal(t, test.args, args)
+	}
+}
+
+func TestCondition_Params(t *testing.T) {
+	builder := builderClass{}
+	sql, args, err := builder.buildSelectSql(&t_mysql.SelectParams{
+		TableName: "table",
+		Select:    "*",
+		Where: map[string]interface{}{
+			"amount": Gt(100),
+		},
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "select * from `table` where `amount` > ?", sql)
+	go_test_.Equal(t, []interface{}{"100"}, args)
+
+	sql, args, err = builder.buildUpdateSql(&t_mysql.UpdateParams{
+		TableName: "table",
+		Update:    map[string]interface{}{"status": 1},
+		Where: map[string]interface{}{
+			"deleted_at": IsNull(),
+		},
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "update `table` set `status` = ? where `deleted_at` is null", sql)
+	go_test_.Equal(t, []interface{}{"1"}, args)
+
+	mysql, recorder := newFakeMysql()
+	_, err = mysql.Count(&t_mysql.CountParams{
+		TableName: "table",
+		Where: map[string]interface{}{
+			"name": Like("%' or 1=1 --"),
+		},
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "select count(*) as count from `table` where `name` like ?", recorder.Stmts()[0])
+}
+
+func TestWhereNode(t *testing.T) {
+	builder := builderClass{}
+	args, sql, err := builder.buildWhere(And(
+		map[string]interface{}{"a": 1},
+		Or(
+			map[string]interface{}{"b": 2},
+			And(
+				map[string]interface{}{"c": Gt(3)},
+				map[string]interface{}{"d": []int{4, 5}},
+			),
+		),
+	), nil)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "where `a` = ? and (`b` = ? or (`c` > ? and `d` in (?,?)))", sql)
+	go_test_.Equal(t, []interface{}{"1", "2", "3", "4", "5"}, args)
+
+	args, sql, err = builder.buildWhere(Or(
+		Not(map[string]interface{}{"a": 1}),
+		Not(Or(
+			map[string]interface{}{"b": IsNull()},
+			map[string]interface{}{"c": Between(1, 2)},
+		)),
+		struct {
+			E string `json:"e"`
+		}{E: "6"},
+	), nil)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "where not (`a` = ?) or not (`b` is null or `c` between ? and ?) or `e` = ?", sql)
+	go_test_.Equal(t, []interface{}{"1", "1", "2", "6"}, args)
+
+	// 空的子条件被忽略
+	args, sql, err = builder.buildWhere(And(
+		map[string]interface{}{"a": []int{}},
+		Or(),
+		Not(And()),
+	), nil)
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "", sql)
+	go_test_.Equal(t, 0, len(args))
+
+	_, _, err = builder.buildWhere(And("`a` = 1"), nil)
+	go_test_.Equal(t, "Where item type error.", err.Error())
+
+	sql, args, err = builder.buildSelectSql(&t_mysql.SelectParams{
+		TableName: "table",
+		Select:    "*",
+		Where: Or(
+			map[string]interface{}{"a": 1},
+			map[string]interface{}{"b": 2},
+		),
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, "select * from `table` where `a` = ? or `b` = ?", sql)
+	go_test_.Equal(t, []interface{}{"1", "2"}, args)
+}
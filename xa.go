@@ -0,0 +1,279 @@
+package go_mysql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// XA 分支使用的 formatID，XA RECOVER 时只处理这个 formatID 的分支
+const xaFormatId int64 = 0x676f
+
+const (
+	xaStateActive = iota
+	xaStateIdle
+	xaStatePrepared
+)
+
+// XaCoordinator 在多个连接池（可以是不同的 mysql 集群）之间执行 XA 分布式事务。
+// 第 i 个参与者的分支 bqual 为 i。提交时先提交分支 0，分支 0 提交成功即视为整个事务提交，
+// 回滚时按相反顺序回滚，Recover 据此判断悬挂的分支应该提交还是回滚，所以参与者的顺序不能变
+type XaCoordinator struct {
+	participants []*MysqlType
+}
+
+func NewXaCoordinator(participants ...*MysqlType) *XaCoordinator {
+	return &XaCoordinator{
+		participants: participants,
+	}
+}
+
+type XaBranch struct {
+	Participant int
+	Gtrid       string
+	Bqual       string
+}
+
+func xaXid(gtrid string, bqual string) string {
+	return fmt.Sprintf(`'%s','%s',%d`, gtrid, bqual, xaFormatId)
+}
+
+func (c *XaCoordinator) validate() error {
+	if len(c.participants) == 0 {
+		return errors.New(`XA coordinator has no participant.`)
+	}
+	for _, participant := range c.participants {
+		if participant.tx != nil || participant.db == nil {
+			return errors.New(`XA participant must be a connected non-transaction instance.`)
+		}
+	}
+	return nil
+}
+
+// Run 在每个参与者上开启一个 XA 分支，fn 拿到的 branches 与参与者一一对应。
+// fn 返回 nil 则两阶段提交，返回错误或者 panic 则全部回滚（panic 会在回滚后重新抛出）。
+// 分支 0 提交失败或者分支 0 提交后其他分支提交失败的话会返回错误，这些分支需要通过 Recover 处理
+func (c *XaCoordinator) Run(ctx context.Context, fn func(branches []*MysqlType) error) (err error) {
+	err = c.validate()
+	if err != nil {
+		return err
+	}
+	gtrid := uuid.New().String()
+	branches := make([]*MysqlType, 0, len(c.participants))
+	for i, participant := range c.participants {
+		branch, err := participant.beginXaBranch(ctx, gtrid, strconv.Itoa(i))
+		if err != nil {
+			c.rollback(branches)
+			return err
+		}
+		branches = append(branches, branch)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			c.rollback(branches)
+			panic(r)
+		}
+	}()
+
+	err = fn(branches)
+	if err != nil {
+		c.rollback(branches)
+		return err
+	}
+
+	for _, branch := range branches {
+		err = branch.execXa(`xa end`)
+		if err != nil {
+			c.rollback(branches)
+			return err
+		}
+		branch.xaState = xaStateIdle
+		err = branch.execXa(`xa prepare`)
+		if err != nil {
+			c.rollback(branches)
+			return err
+		}
+		branch.xaState = xaStatePrepared
+	}
+
+	// 分支 0 是提交点
+	err = branches[0].execXa(`xa commit`)
+	if err != nil {
+		// 分支 0 可能已经在服务端提交了（比如连接断开），不能回滚其他分支，全部留给 Recover 处理
+		for _, branch := range branches {
+			branch.logger.Error(fmt.Sprintf(`%sxa commit point failed, branch is left in doubt. err: %v`, branch.txInfo(), err))
+			branch.finishXa(txStateCommitted)
+		}
+		return errors.WithMessagef(err, `XA transaction %s is in doubt, run Recover to resolve it`, gtrid)
+	}
+	branches[0].finishXa(txStateCommitted)
+	branches[0].hooks.fireCommit(branches[0])
+
+	var inDoubtErr error
+	for _, branch := range branches[1:] {
+		err := branch.execXa(`xa commit`)
+		if err != nil {
+			branch.logger.Error(fmt.Sprintf(`%sxa commit failed, branch is left in doubt. err: %v`, branch.txInfo(), err))
+			// 悬挂的分支由 Recover 提交，这里不执行回调
+			branch.finishXa(txStateCommitted)
+			if inDoubtErr == nil {
+				inDoubtErr = errors.WithMessagef(err, `XA transaction %s committed, but some branches are left in doubt`, gtrid)
+			}
+			continue
+		}
+		branch.finishXa(txStateCommitted)
+		branch.hooks.fireCommit(branch)
+	}
+	return inDoubtErr
+}
+
+func (c *XaCoordinator) rollback(branches []*MysqlType) {
+	for i := len(branches) - 1; i >= 0; i-- {
+		branch := branches[i]
+		if branch.txDone() {
+			continue
+		}
+		if branch.xaState == xaStateActive {
+			err := branch.execXa(`xa end`)
+			if err != nil {
+				branch.logger.Error(fmt.Sprintf(`%sxa end failed. err: %v`, branch.txInfo(), err))
+			}
+		}
+		err := branch.execXa(`xa rollback`)
+		if err != nil {
+			branch.logger.Error(fmt.Sprintf(`%sxa rollback failed. err: %v`, branch.txInfo(), err))
+		}
+		branch.finishXa(txStateRolledBack)
+		branch.hooks.fireRollback(branch)
+	}
+}
+
+// InDoubt 通过 XA RECOVER 列出所有参与者上处于 prepared 状态的分支
+func (c *XaCoordinator) InDoubt(ctx context.Context) ([]*XaBranch, error) {
+	err := c.validate()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*XaBranch, 0)
+	for i, participant := range c.participants {
+		rows := make([]struct {
+			FormatId    int64  `json:"formatID"`
+			GtridLength int    `json:"gtrid_length"`
+			BqualLength int    `json:"bqual_length"`
+			Data        string `json:"data"`
+		}, 0)
+		// 直接查主库，不走从库以及 ctx 中的事务
+		participant.printDebugInfo(`xa recover`, nil)
+		err := participant.db.SelectContext(ctx, &rows, `xa recover`)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, row := range rows {
+			if row.FormatId != xaFormatId || len(row.Data) < row.GtridLength+row.BqualLength {
+				continue
+			}
+			results = append(results, &XaBranch{
+				Participant: i,
+				Gtrid:       row.Data[:row.GtridLength],
+				Bqual:       row.Data[row.GtridLength : row.GtridLength+row.BqualLength],
+			})
+		}
+	}
+	return results, nil
+}
+
+// Recover 处理悬挂的分支：分支 0 还在说明提交点之前就中断了，全部回滚；否则全部提交。
+// 只能在没有 Run 正在执行的时候调用，否则会处理掉正在两阶段提交中的分支
+func (c *XaCoordinator) Recover(ctx context.Context) error {
+	branches, err := c.InDoubt(ctx)
+	if err != nil {
+		return err
+	}
+	commitPointPassed := make(map[string]bool)
+	for _, branch := range branches {
+		if _, ok := commitPointPassed[branch.Gtrid]; !ok {
+			commitPointPassed[branch.Gtrid] = true
+		}
+		if branch.Bqual == "0" {
+			commitPointPassed[branch.Gtrid] = false
+		}
+	}
+	sort.SliceStable(branches, func(i, j int) bool {
+		return branches[i].Participant > branches[j].Participant
+	})
+
+	for _, branch := range branches {
+		action := `rollback`
+		if commitPointPassed[branch.Gtrid] {
+			action = `commit`
+		}
+		participant := c.participants[branch.Participant]
+		sql := fmt.Sprintf(`xa %s %s`, action, xaXid(branch.Gtrid, branch.Bqual))
+		participant.logger.Info(fmt.Sprintf(`[transaction id: %s:%s] recover in-doubt xa branch. %s`, branch.Gtrid, branch.Bqual, sql))
+		_, err := participant.db.ExecContext(ctx, sql)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (mc *MysqlType) beginXaBranch(ctx context.Context, gtrid string, bqual string) (*MysqlType, error) {
+	tx, err := mc.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	branch := &MysqlType{
+		db:           nil,
+		txId:         fmt.Sprintf(`%s:%s`, gtrid, bqual),
+		tx:           tx,
+		savepointSeq: new(uint64),
+		hooks:        &txHooks{},
+		busy:         new(int32),
+		xid:          xaXid(gtrid, bqual),
+		xaState:      xaStateActive,
+		txTracker:    mc.txTracker,
+		tagName:      mc.tagName,
+		logger:       mc.logger,
+	}
+	// 驱动开启的是普通事务，先提交掉，再在同一个连接上开启 XA 事务
+	for _, sql := range []string{`commit`, `xa start ` + branch.xid} {
+		branch.printDebugInfo(sql, nil)
+		_, err = tx.ExecContext(ctx, sql)
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, errors.WithStack(err)
+		}
+	}
+	return branch, nil
+}
+
+func (mc *MysqlType) execXa(action string) error {
+	leave, err := mc.enterTx()
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	sql := fmt.Sprintf(`%s %s`, action, mc.xid)
+	mc.printDebugInfo(sql, nil)
+	_, err = mc.tx.Exec(sql)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// finishXa 标记分支结束并把连接还给连接池
+func (mc *MysqlType) finishXa(state int32) {
+	mc.finishTx(state)
+	// XA 事务已经结束，这里的 rollback 只是为了让 database/sql 释放连接
+	err := mc.tx.Rollback()
+	if err != nil {
+		mc.logger.Error(fmt.Sprintf(`%srelease xa connection failed. err: %v`, mc.txInfo(), err))
+	}
+}
@@ -0,0 +1,131 @@
+package go_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	go_test_ "github.com/pefish/go-test"
+)
+
+func TestXaCoordinator_Run(t *testing.T) {
+	mysql0, recorder0 := newFakeMysql()
+	mysql1, recorder1 := newFakeMysql()
+	coordinator := NewXaCoordinator(mysql0, mysql1)
+
+	var gtrid string
+	committed := false
+	err := coordinator.Run(context.Background(), func(branches []*MysqlType) error {
+		gtrid = strings.Split(branches[0].txId, ":")[0]
+		go_test_.Equal(t, ErrXaBranch, branches[0].Commit())
+		branches[1].OnCommit(func() { committed = true })
+		_, err := branches[0].RawExec("update `a` set `v` = 1")
+		if err != nil {
+			return err
+		}
+		_, err = branches[1].RawExec("update `b` set `v` = 1")
+		return err
+	})
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, true, committed)
+	go_test_.Equal(t, []string{
+		"begin",
+		"commit",
+		"xa start " + xaXid(gtrid, "0"),
+		"update `a` set `v` = 1",
+		"xa end " + xaXid(gtrid, "0"),
+		"xa prepare " + xaXid(gtrid, "0"),
+		"xa commit " + xaXid(gtrid, "0"),
+		"rollback",
+	}, recorder0.Stmts())
+	go_test_.Equal(t, "xa commit "+xaXid(gtrid, "1"), recorder1.Stmts()[6])
+
+	mysql0, recorder0 = newFakeMysql()
+	mysql1, recorder1 = newFakeMysql()
+	recorder1.execErr = func(query string) error {
+		if strings.HasPrefix(query, "xa prepare") {
+			return errors.New("prepare failed")
+		}
+		return nil
+	}
+	err = NewXaCoordinator(mysql0, mysql1).Run(context.Background(), func(branches []*MysqlType) error {
+		gtrid = strings.Split(branches[0].txId, ":")[0]
+		return nil
+	})
+	go_test_.NotEqual(t, nil, err)
+	go_test_.Equal(t, "xa rollback "+xaXid(gtrid, "0"), recorder0.Stmts()[5])
+	go_test_.Equal(t, "xa rollback "+xaXid(gtrid, "1"), recorder1.Stmts()[5])
+
+	// 提交点结果未知时不能回滚其他分支
+	mysql0, recorder0 = newFakeMysql()
+	mysql1, recorder1 = newFakeMysql()
+	recorder0.execErr = func(query string) error {
+		if strings.HasPrefix(query, "xa commit") {
+			return errors.New("connection lost")
+		}
+		return nil
+	}
+	hookFired := false
+	err = NewXaCoordinator(mysql0, mysql1).Run(context.Background(), func(branches []*MysqlType) error {
+		gtrid = strings.Split(branches[0].txId, ":")[0]
+		for _, branch := range branches {
+			branch.OnCommit(func() { hookFired = true })
+			branch.OnRollback(func() { hookFired = true })
+		}
+		return nil
+	})
+	go_test_.NotEqual(t, nil, err)
+	go_test_.Equal(t, true, strings.Contains(err.Error(), "in doubt"))
+	go_test_.Equal(t, false, hookFired)
+	go_test_.Equal(t, []string{
+		"xa end " + xaXid(gtrid, "1"),
+		"xa prepare " + xaXid(gtrid, "1"),
+		"rollback",
+	}, recorder1.Stmts()[3:])
+	for _, stmt := range recorder0.Stmts() {
+		go_test_.Equal(t, false, strings.HasPrefix(stmt, "xa rollback"))
+	}
+}
+
+func TestXaCoordinator_Recover(t *testing.T) {
+	mysql0, recorder0 := newFakeMysql()
+	mysql1, recorder1 := newFakeMysql()
+	xaRows := func(branches ...string) func(query string) ([]string, [][]driver.Value) {
+		return func(query string) ([]string, [][]driver.Value) {
+			values := make([][]driver.Value, 0)
+			for _, branch := range branches {
+				parts := strings.Split(branch, ":")
+				values = append(values, []driver.Value{
+					xaFormatId,
+					int64(len(parts[0])),
+					int64(len(parts[1])),
+					[]byte(parts[0] + parts[1]),
+				})
+			}
+			values = append(values, []driver.Value{int64(1), int64(3), int64(0), []byte("foo")})
+			return []string{"formatID", "gtrid_length", "bqual_length", "data"}, values
+		}
+	}
+	recorder0.rows = xaRows("aaa:0")
+	recorder1.rows = xaRows("aaa:1", "bbb:1")
+	// 分支在主库上，不能查从库
+	replica, replicaRecorder := newFakeReplica("replica", 1)
+	mysql0.replicas = []*Replica{replica}
+	mysql0.balancer = &RoundRobinBalancer{}
+	coordinator := NewXaCoordinator(mysql0, mysql1)
+
+	branches, err := coordinator.InDoubt(context.Background())
+	go_test_.Equal(t, nil, err)
+	go_test_.Equal(t, 3, len(branches))
+
+	go_test_.Equal(t, nil, coordinator.Recover(context.Background()))
+	go_test_.Equal(t, []string{"xa recover", "xa rollback " + xaXid("aaa", "0")}, recorder0.Stmts()[1:])
+	go_test_.Equal(t, []string{
+		"xa recover",
+		"xa rollback " + xaXid("aaa", "1"),
+		"xa commit " + xaXid("bbb", "1"),
+	}, recorder1.Stmts()[1:])
+	go_test_.Equal(t, 0, len(replicaRecorder.Stmts()))
+}